@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+)
+
+// mediaexts are the extensions the browser lists unless it shows every
+// file
+var mediaexts = []string{
+	// video
+	".mp4", ".m4v", ".mkv", ".webm", ".avi", ".mov", ".wmv", ".flv",
+	".ts", ".m2ts", ".mts", ".mpg", ".mpeg", ".ogv", ".3gp",
+	// audio
+	".mp3", ".flac", ".wav", ".ogg", ".oga", ".opus", ".m4a", ".aac",
+	".wma", ".ac3", ".dts",
+	// playlists
+	".m3u", ".m3u8", ".pls",
+}
+
+func ismedia(name string) bool {
+	return slices.Contains(mediaexts, strings.ToLower(filepath.Ext(name)))
+}
+
+// browser is the panel files are picked from
+type browser struct {
+	open bool
+	dir  string
+	all  bool
+
+	// the listing of dir, folders first
+	entries []os.DirEntry
+	// why dir couldn't be listed or the last file couldn't be opened
+	err string
+
+	recent *recentfiles
+}
+
+func newbrowser(recent *recentfiles) *browser {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir, _ = os.UserHomeDir()
+	}
+
+	b := &browser{recent: recent}
+	b.chdir(dir)
+	return b
+}
+
+func (b *browser) toggle() {
+	b.open = !b.open
+	if b.open {
+		// pick up what changed on disk meanwhile
+		b.chdir(b.dir)
+	}
+}
+
+// chdir lists dir, the listing stays as it was when it can't be read
+func (b *browser) chdir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		b.err = err.Error()
+		return
+	}
+
+	b.dir = dir
+	b.err = ""
+	b.entries = b.entries[:0]
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if e.IsDir() || b.all || ismedia(e.Name()) {
+			b.entries = append(b.entries, e)
+		}
+	}
+
+	// os.ReadDir sorts by name already
+	slices.SortStableFunc(b.entries, func(x, y os.DirEntry) int {
+		switch {
+		case x.IsDir() && !y.IsDir():
+			return -1
+		case !x.IsDir() && y.IsDir():
+			return 1
+		}
+		return 0
+	})
+}
+
+// draw shows the panel and returns the file picked in it, if any
+func (b *browser) draw(w, h float32) (string, bool) {
+	if !b.open {
+		return "", false
+	}
+
+	imgui.SetNextWindowPosV(imgui.Vec2{X: w / 2, Y: h / 2}, imgui.CondAppearing, imgui.Vec2{X: 0.5, Y: 0.5})
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: min(w*0.8, 640), Y: min(h*0.8, 480)}, imgui.CondAppearing)
+
+	var picked string
+	if imgui.BeginV("Open file", &b.open, imgui.WindowFlagsNoCollapse) {
+		picked = b.drawrecent()
+
+		imgui.SeparatorText("Browse")
+		if imgui.Button("Up") {
+			b.chdir(filepath.Dir(b.dir))
+		}
+		imgui.SameLine()
+		if imgui.Button("Home") {
+			if home, err := os.UserHomeDir(); err == nil {
+				b.chdir(home)
+			}
+		}
+		imgui.SameLine()
+		if imgui.Checkbox("All files", &b.all) {
+			b.chdir(b.dir)
+		}
+		imgui.SameLine()
+		imgui.Text(escape(b.dir))
+
+		if b.err != "" {
+			imgui.TextColored(imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1}, escape(b.err))
+		}
+
+		if imgui.BeginChildStrV("##entries", imgui.Vec2{}, imgui.ChildFlagsBorders, 0) {
+			if p := b.drawentries(); p != "" {
+				picked = p
+			}
+		}
+		imgui.EndChild()
+	}
+	imgui.End()
+
+	if picked == "" {
+		return "", false
+	}
+	return picked, true
+}
+
+// drawrecent lists the recent files and returns the one clicked
+func (b *browser) drawrecent() string {
+	if len(b.recent.files) == 0 {
+		return ""
+	}
+
+	imgui.SeparatorText("Recent")
+
+	var picked string
+	for i, f := range b.recent.files {
+		if imgui.SelectableBool(fmt.Sprintf("%s##recent%d", filepath.Base(f), i)) {
+			picked = f
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip(escape(f))
+		}
+	}
+	if imgui.SmallButton("Clear") {
+		if err := b.recent.clear(); err != nil {
+			b.err = err.Error()
+		}
+	}
+
+	return picked
+}
+
+// drawentries lists the current folder, a double click enters a folder
+// or returns the file
+func (b *browser) drawentries() string {
+	var picked, enter string
+
+	for i, e := range b.entries {
+		label := e.Name()
+		if e.IsDir() {
+			label += "/"
+		}
+
+		if imgui.SelectableBoolV(fmt.Sprintf("%s##entry%d", label, i), false, imgui.SelectableFlagsAllowDoubleClick, imgui.Vec2{}) &&
+			imgui.IsMouseDoubleClicked(imgui.MouseButtonLeft) {
+			path := filepath.Join(b.dir, e.Name())
+			if e.IsDir() {
+				enter = path
+			} else {
+				picked = path
+			}
+		}
+	}
+
+	// not while ranging over the entries chdir replaces
+	if enter != "" {
+		b.chdir(enter)
+	}
+	return picked
+}
+
+// escape keeps imgui from reading text as a format string
+func escape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"GoldenFealla/go-video-player/player"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// errusage is returned for flags the flag package rejected, it already
+// printed why along with the usage
+var errusage = errors.New("usage")
+
+// options is what the command line asks for
+type options struct {
+	files []string
+
+	start      float32
+	volume     int
+	mute       bool
+	fullscreen bool
+	borderless bool
+	ontop      bool
+	width      int
+	height     int
+	loop       int
+	speed      float64
+
+	// playlist options
+	shuffle      bool
+	loopplaylist bool
+
+	// the size was given, the window isn't fitted to the video then
+	sized bool
+	// the window is the video size times scale
+	scale float64
+
+	// stream indexes as listed by the tracks, -1 keeps the default
+	aid int
+	vid int
+	sid int
+
+	novideo bool
+	noaudio bool
+
+	// key bindings file, empty for the default one
+	inputconf string
+
+	help    bool
+	version bool
+}
+
+const usage = `Usage: go-video-player [options] <file|url|playlist>...
+
+Plays the given files or URLs one after the other, M3U, M3U8 and PLS
+playlists are expanded into the files they list, HLS ones and URLs play
+as a single stream.
+
+Options:
+`
+
+// parseargs reads the command line, the flags may come before, after or
+// between the media paths
+func parseargs(args []string, out io.Writer) (options, error) {
+	o := options{}
+
+	fs := flag.NewFlagSet("go-video-player", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage)
+		fs.PrintDefaults()
+	}
+
+	var start, size, loop string
+
+	fs.StringVar(&start, "start", "", "start position, seconds or [[hh:]mm:]ss")
+	fs.IntVar(&o.volume, "volume", 50, "volume from 0 to 100")
+	fs.BoolVar(&o.mute, "mute", false, "start muted")
+	fs.BoolVar(&o.fullscreen, "fullscreen", false, "start in fullscreen")
+	fs.BoolVar(&o.borderless, "borderless", false, "open the window without decorations")
+	fs.BoolVar(&o.ontop, "ontop", false, "keep the window above the others")
+	fs.StringVar(&size, "size", "1280x720", "window size as WxH, the video size when not given")
+	fs.Float64Var(&o.scale, "window-scale", 1, "window size relative to the video size")
+	fs.StringVar(&loop, "loop", "0", "play each file N more times, inf repeats forever")
+	fs.BoolVar(&o.shuffle, "shuffle", false, "play the files in random order")
+	fs.BoolVar(&o.loopplaylist, "loop-playlist", false, "start over after the last file")
+	fs.Float64Var(&o.speed, "speed", 1, fmt.Sprintf("playback speed from %g to %g", player.MinSpeed, player.MaxSpeed))
+	fs.IntVar(&o.aid, "aid", -1, "audio track index")
+	fs.IntVar(&o.vid, "vid", -1, "video track index")
+	fs.IntVar(&o.sid, "sid", -1, "subtitle track index")
+	fs.BoolVar(&o.novideo, "no-video", false, "play the audio only")
+	fs.BoolVar(&o.noaudio, "no-audio", false, "play the video only")
+	fs.StringVar(&o.inputconf, "input-conf", "", "key bindings file, see input.conf in the README")
+	fs.BoolVar(&o.version, "version", false, "print the version and exit")
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				o.help = true
+				return o, nil
+			}
+			return o, errusage
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		o.files = append(o.files, args[0])
+		args = args[1:]
+	}
+
+	if o.version {
+		return o, nil
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "size" {
+			o.sized = true
+		}
+	})
+
+	var err error
+	if start != "" {
+		if o.start, err = parsetime(start); err != nil {
+			return o, fmt.Errorf("invalid --start %q: %w", start, err)
+		}
+	}
+	if o.width, o.height, err = parsesize(size); err != nil {
+		return o, fmt.Errorf("invalid --size %q: %w", size, err)
+	}
+	if o.loop, err = parseloop(loop); err != nil {
+		return o, fmt.Errorf("invalid --loop %q: %w", loop, err)
+	}
+	if o.scale <= 0 {
+		return o, fmt.Errorf("invalid --window-scale %g: not positive", o.scale)
+	}
+	if o.volume < 0 || o.volume > 100 {
+		return o, fmt.Errorf("invalid --volume %d: out of 0..100", o.volume)
+	}
+	if o.speed < player.MinSpeed || o.speed > player.MaxSpeed {
+		return o, fmt.Errorf("invalid --speed %g: out of %g..%g", o.speed, player.MinSpeed, player.MaxSpeed)
+	}
+	if o.novideo && o.noaudio {
+		return o, errors.New("--no-video and --no-audio leave nothing to play")
+	}
+	if len(o.files) == 0 {
+		return o, errors.New("no file or URL to play")
+	}
+
+	return o, nil
+}
+
+// parsetime reads seconds or [[hh:]mm:]ss, the seconds may have decimals
+func parsetime(s string) (float32, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, errors.New("too many fields")
+	}
+
+	var t float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, errors.New("not a position")
+		}
+		// only the seconds may have decimals
+		if i < len(parts)-1 && v != float64(int(v)) {
+			return 0, errors.New("not a position")
+		}
+		t = t*60 + v
+	}
+
+	return float32(t), nil
+}
+
+// parsesize reads WxH
+func parsesize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, errors.New("expected WxH")
+	}
+
+	w, err := strconv.Atoi(ws)
+	if err != nil || w <= 0 {
+		return 0, 0, errors.New("bad width")
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil || h <= 0 {
+		return 0, 0, errors.New("bad height")
+	}
+
+	return w, h, nil
+}
+
+// parseloop reads a loop count, inf meaning forever
+func parseloop(s string) (int, error) {
+	switch s {
+	case "inf", "infinite":
+		return -1, nil
+	case "no":
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a count or inf")
+	}
+	return n, nil
+}
+
+// loadmedia opens path in p and applies the options that are per media,
+// the playback is left to the caller
+func loadmedia(p *player.Player, o options, path string) error {
+	if err := p.Load(path); err != nil {
+		return fmt.Errorf("cannot open %s: %w", path, err)
+	}
+
+	if o.vid >= 0 {
+		if err := p.SelectVideoTrack(o.vid); err != nil {
+			log.Println(fmt.Errorf("selecting video track %d failed: %w", o.vid, err))
+		}
+	}
+	if o.aid >= 0 {
+		if err := p.SelectAudioTrack(o.aid); err != nil {
+			log.Println(fmt.Errorf("selecting audio track %d failed: %w", o.aid, err))
+		}
+	}
+	if o.sid >= 0 {
+		if err := p.SelectSubtitleTrack(o.sid); err != nil {
+			log.Println(fmt.Errorf("selecting subtitle track %d failed: %w", o.sid, err))
+		}
+	}
+
+	if o.start > 0 {
+		p.SeekSecond(o.start)
+	}
+
+	return nil
+}
+
+// fail prints err the way the command line tools do and exits
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "go-video-player: %v\n", err)
+	os.Exit(1)
+}
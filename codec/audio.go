@@ -9,6 +9,59 @@ import (
 	"github.com/asticode/go-astikit"
 )
 
+// AudioFormat is the format decoded audio is resampled to before it is
+// pushed to the AudioBuffer, samples are always interleaved
+type AudioFormat struct {
+	SampleRate   int
+	Channels     int
+	SampleFormat astiav.SampleFormat
+}
+
+// BytesPerSecond returns the size of one second of audio in f
+func (f AudioFormat) BytesPerSecond() int {
+	return f.SampleRate * f.Channels * f.SampleFormat.BytesPerSample()
+}
+
+// channellayout maps a channel count to the layout SDL expects for it
+func channellayout(channels int) (astiav.ChannelLayout, bool) {
+	switch channels {
+	case 1:
+		return astiav.ChannelLayoutMono, true
+	case 2:
+		return astiav.ChannelLayoutStereo, true
+	case 3:
+		return astiav.ChannelLayout2Point1, true
+	case 4:
+		return astiav.ChannelLayoutQuad, true
+	case 6:
+		return astiav.ChannelLayout5Point1, true
+	case 7:
+		return astiav.ChannelLayout6Point1, true
+	case 8:
+		return astiav.ChannelLayout7Point1, true
+	}
+	return astiav.ChannelLayout{}, false
+}
+
+// SupportedAudioFormat reports whether decoded audio can be resampled to f
+func SupportedAudioFormat(f AudioFormat) bool {
+	if _, ok := channellayout(f.Channels); !ok {
+		return false
+	}
+
+	switch f.SampleFormat {
+	case astiav.SampleFormatS16, astiav.SampleFormatFlt:
+		return f.SampleRate > 0
+	}
+	return false
+}
+
+var defaultaudioformat = AudioFormat{
+	SampleRate:   44100,
+	Channels:     2,
+	SampleFormat: astiav.SampleFormatS16,
+}
+
 type audiodecoder struct {
 	closer *astikit.Closer
 	ctx    *astiav.CodecContext
@@ -19,6 +72,15 @@ type audiodecoder struct {
 
 	hasaudio bool
 	tb       astiav.Rational
+
+	out    AudioFormat
+	layout astiav.ChannelLayout
+
+	// set when the source has more channels than the output, the
+	// resampler then keeps every channel as float and mx folds them
+	mx *mixer
+
+	gate seekgate
 }
 
 func newaudiodecoder() *audiodecoder {
@@ -26,11 +88,43 @@ func newaudiodecoder() *audiodecoder {
 	ad.closer = astikit.NewCloser()
 
 	ad.src = astiav.AllocSoftwareResampleContext()
-	ad.closer.Add(ad.src.Free)
+	ad.closer.Add(func() {
+		ad.src.Free()
+	})
+
+	ad.setformat(defaultaudioformat, Downmix{})
 
 	return ad
 }
 
+// setformat changes the resampler output, the resampler is recreated as
+// it can't be reconfigured once it has converted a frame
+func (ad *audiodecoder) setformat(f AudioFormat, d Downmix) error {
+	layout, ok := channellayout(f.Channels)
+	if !ok || !SupportedAudioFormat(f) {
+		return fmt.Errorf("audio decoder: unsupported output format %+v", f)
+	}
+
+	ad.src.Free()
+	ad.src = astiav.AllocSoftwareResampleContext()
+
+	ad.out = f
+	ad.layout = layout
+	ad.mx = nil
+
+	if ad.ctx == nil {
+		return nil
+	}
+
+	if in := SupportedChannels(ad.ctx.ChannelLayout().Channels()); in > f.Channels {
+		ad.layout, _ = channellayout(in)
+		ad.mx = newmixer(in, f.Channels, f.SampleFormat, d)
+		log.Printf("audio decoder: downmixing %d to %d channels with %s\n", in, f.Channels, d.Matrix)
+	}
+
+	return nil
+}
+
 func (ad *audiodecoder) close() {
 	ad.closer.Close()
 }
@@ -45,7 +139,7 @@ func (ad *audiodecoder) load(stream *astiav.Stream) error {
 	if ad.ctx == nil {
 		return errors.New("audio decoder: codec context is nil")
 	}
-	// ad.closer.Add(ad.ctx.Free)
+	ad.closer.Add(ad.ctx.Free)
 
 	err := stream.CodecParameters().ToCodecContext(ad.ctx)
 	if err != nil {
@@ -70,10 +164,13 @@ func (ad *audiodecoder) load(stream *astiav.Stream) error {
 	return nil
 }
 
-func (ad *audiodecoder) decode(pkt *astiav.Packet, aBuffer *AudioBuffer) error {
-	ad.r.SetSampleFormat(astiav.SampleFormatS16)
-	ad.r.SetChannelLayout(astiav.ChannelLayoutStereo)
-	ad.r.SetSampleRate(44100)
+func (ad *audiodecoder) decode(pkt *astiav.Packet, aBuffer *RingBuffer[AudioData], serial uint64) error {
+	ad.r.SetSampleFormat(ad.out.SampleFormat)
+	if ad.mx != nil {
+		ad.r.SetSampleFormat(astiav.SampleFormatFlt)
+	}
+	ad.r.SetChannelLayout(ad.layout)
+	ad.r.SetSampleRate(ad.out.SampleRate)
 
 	err := ad.ctx.SendPacket(pkt)
 	if err != nil {
@@ -89,7 +186,7 @@ func (ad *audiodecoder) decode(pkt *astiav.Packet, aBuffer *AudioBuffer) error {
 		if stop := func() bool {
 			if err := ad.ctx.ReceiveFrame(ad.f); err != nil {
 				if errors.Is(err, astiav.ErrEagain) {
-					log.Println(fmt.Errorf("audio decode eagain: %w", err))
+					// log.Println(fmt.Errorf("audio decode eagain: %w", err))
 				} else if errors.Is(err, astiav.ErrEof) {
 					log.Println(fmt.Errorf("audio decode eof: %w", err))
 				} else {
@@ -112,14 +209,39 @@ func (ad *audiodecoder) decode(pkt *astiav.Packet, aBuffer *AudioBuffer) error {
 
 			if nbSamples := ad.r.NbSamples(); nbSamples > 0 {
 				src, _ := ad.r.Data().Bytes(1)
+				if ad.mx != nil {
+					src = ad.mx.mix(src)
+				}
+
+				pts := float64(ad.f.Pts()) * ad.tb.Float64()
+				framesize := ad.out.Channels * ad.out.SampleFormat.BytesPerSample()
+				if framesize <= 0 || ad.out.SampleRate <= 0 {
+					return true
+				}
+				end := pts + float64(len(src)/framesize)/float64(ad.out.SampleRate)
 
-				aBuffer.Push(AudioData{
-					PTS:     float64(ad.f.Pts()) * ad.tb.Float64(),
-					Samples: src,
+				// cut the samples before a precise seek target
+				if g := &ad.gate; g.drop && pts < g.target && end > g.target {
+					cut := int((g.target-pts)*float64(ad.out.SampleRate)) * framesize
+					src = src[min(cut, len(src)):]
+					pts = g.target
+				}
+				if !ad.gate.pass(pts, end) {
+					return false
+				}
+
+				ok := aBuffer.Push(AudioData{
+					PTS:      pts,
+					Duration: end - pts,
+					Samples:  src,
+					Serial:   serial,
 				})
+
+				// buffer closed, nobody is going to read the rest
+				return !ok
 			}
 
-			return true
+			return false
 		}(); stop {
 			break
 		}
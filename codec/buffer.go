@@ -1,164 +1,448 @@
 package codec
 
 import (
+	"context"
+	"errors"
 	"sync"
-)
 
-type AudioData struct {
-	PTS     float64
-	Samples []byte
-}
+	"github.com/asticode/go-astiav"
+)
 
-type AudioBuffer struct {
-	data []AudioData
+// ErrBufferClosed is returned by the blocking buffer calls once the
+// buffer is closed, and by the Peek and Pop ones only after it is
+// drained too
+var ErrBufferClosed = errors.New("codec: buffer closed")
+
+// RingBuffer is a bounded FIFO shared by one producer and one consumer.
+//
+// Every call comes in three flavours: the plain one blocks, the Context
+// one blocks until ctx is done, the Try one never blocks. Close wakes
+// every waiter, pushing fails from then on while Peek and Pop keep
+// draining what is left.
+//
+// The buffer is full once it holds its item capacity or reaches one of
+// the optional byte and duration limits. The last item may go past a
+// limit, a single item is always accepted so a huge frame can't
+// deadlock the producer.
+type RingBuffer[T any] struct {
+	data []T
 	r, w int
 	size int
 	capa int
 
+	// optional weight limits, zero means unlimited
+	maxbytes int
+	bytes    int
+	sizeof   func(T) int
+
+	maxdur float64
+	dur    float64
+	durof  func(T) float64
+
+	// called on the items Clear drops
+	release func(T)
+
+	closed bool
+
 	mu   sync.Mutex
 	cond *sync.Cond
 }
 
-func NewAudioBuffer(capacity int) *AudioBuffer {
-	ab := &AudioBuffer{
-		data: make([]AudioData, capacity),
+// RingOption sets a limit on a RingBuffer, see NewRingBuffer
+type RingOption[T any] func(*RingBuffer[T])
+
+// LimitBytes bounds the buffer to about n bytes as measured by sizeof,
+// with n <= 0 the size is only measured, see Bytes
+func LimitBytes[T any](n int, sizeof func(T) int) RingOption[T] {
+	return func(rb *RingBuffer[T]) {
+		rb.maxbytes = n
+		rb.sizeof = sizeof
+	}
+}
+
+// LimitDuration bounds the buffer to about d seconds as measured by
+// durof, with d <= 0 the duration is only measured, see Duration
+func LimitDuration[T any](d float64, durof func(T) float64) RingOption[T] {
+	return func(rb *RingBuffer[T]) {
+		rb.maxdur = d
+		rb.durof = durof
+	}
+}
+
+// OnClear calls release on every item Clear drops, so pooled resources
+// find their way back
+func OnClear[T any](release func(T)) RingOption[T] {
+	return func(rb *RingBuffer[T]) {
+		rb.release = release
+	}
+}
+
+// NewRingBuffer makes a buffer of at most capacity items
+func NewRingBuffer[T any](capacity int, opts ...RingOption[T]) *RingBuffer[T] {
+	capacity = max(capacity, 1)
+	rb := &RingBuffer[T]{
+		data: make([]T, capacity),
 		capa: capacity,
 	}
-	ab.cond = sync.NewCond(&ab.mu)
-	return ab
+	rb.cond = sync.NewCond(&rb.mu)
+
+	for _, opt := range opts {
+		opt(rb)
+	}
+
+	return rb
 }
 
-func (ab *AudioBuffer) Push(d AudioData) bool {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
+// full needs rb.mu held
+func (rb *RingBuffer[T]) full() bool {
+	if rb.size == rb.capa {
+		return true
+	}
+	if rb.size == 0 {
+		return false
+	}
+	if rb.maxbytes > 0 && rb.bytes >= rb.maxbytes {
+		return true
+	}
+	if rb.maxdur > 0 && rb.dur >= rb.maxdur {
+		return true
+	}
+	return false
+}
 
-	for ab.size == ab.capa {
-		ab.cond.Wait()
+// wait blocks until ready returns true, the buffer is closed or ctx is
+// done, rb.mu must be held
+func (rb *RingBuffer[T]) wait(ctx context.Context, ready func() bool) error {
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() {
+			rb.mu.Lock()
+			defer rb.mu.Unlock()
+			rb.cond.Broadcast()
+		})
+		defer stop()
 	}
 
-	ab.data[ab.w] = d
-	ab.w = (ab.w + 1) % ab.capa
-	ab.size++
+	for !ready() && !rb.closed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rb.cond.Wait()
+	}
+	return ctx.Err()
+}
 
-	ab.cond.Signal()
-	return true
+// Push waits for room and adds v, it returns false once the buffer is
+// closed
+func (rb *RingBuffer[T]) Push(v T) bool {
+	return rb.PushContext(context.Background(), v) == nil
 }
 
-func (ab *AudioBuffer) Peek() *AudioData {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
+// PushContext is Push giving up when ctx is done
+func (rb *RingBuffer[T]) PushContext(ctx context.Context, v T) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
 
-	for ab.size == 0 {
-		ab.cond.Wait()
+	if err := rb.wait(ctx, func() bool { return !rb.full() }); err != nil {
+		return err
+	}
+	if rb.closed {
+		return ErrBufferClosed
 	}
 
-	return &ab.data[ab.r]
+	rb.push(v)
+	return nil
 }
 
-func (ab *AudioBuffer) Pop() {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
+// TryPush adds v only if there is room, it never blocks
+func (rb *RingBuffer[T]) TryPush(v T) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
 
-	for ab.size == 0 {
-		ab.cond.Wait()
+	if rb.closed || rb.full() {
+		return false
 	}
 
-	ab.data[ab.r] = AudioData{}
-	ab.r = (ab.r + 1) % ab.capa
-	ab.size--
-	ab.cond.Signal()
+	rb.push(v)
+	return true
 }
 
-func (ab *AudioBuffer) Clear() {
-	ab.mu.Lock()
-	defer ab.mu.Unlock()
+func (rb *RingBuffer[T]) push(v T) {
+	rb.data[rb.w] = v
+	rb.w = (rb.w + 1) % rb.capa
+	rb.size++
+	if rb.sizeof != nil {
+		rb.bytes += rb.sizeof(v)
+	}
+	if rb.durof != nil {
+		rb.dur += rb.durof(v)
+	}
 
-	ab.r = 0
-	ab.w = 0
-	ab.size = 0
+	rb.cond.Broadcast()
+}
 
-	for i := range ab.data {
-		ab.data[i] = AudioData{}
+// Peek waits for an item and returns it without removing it, ok is false
+// once the buffer is closed and drained
+func (rb *RingBuffer[T]) Peek() (v T, ok bool) {
+	v, err := rb.PeekContext(context.Background())
+	return v, err == nil
+}
+
+// PeekContext is Peek giving up when ctx is done
+func (rb *RingBuffer[T]) PeekContext(ctx context.Context) (T, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	var zero T
+	if err := rb.wait(ctx, func() bool { return rb.size > 0 }); err != nil {
+		return zero, err
+	}
+
+	// closed and drained
+	if rb.size == 0 {
+		return zero, ErrBufferClosed
 	}
 
-	ab.cond.Broadcast()
+	return rb.data[rb.r], nil
 }
 
-type VideoData struct {
-	PTS  float64
-	W    int
-	H    int
-	Data []byte
+// TryPeek returns the oldest item, ok is false when the buffer is empty
+func (rb *RingBuffer[T]) TryPeek() (v T, ok bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.size == 0 {
+		return v, false
+	}
+	return rb.data[rb.r], true
 }
 
-type VideoBuffer struct {
-	data []VideoData
-	r, w int
-	size int
-	capa int
+// Pop waits for an item and removes it, ok is false once the buffer is
+// closed and drained
+func (rb *RingBuffer[T]) Pop() (v T, ok bool) {
+	v, err := rb.PopContext(context.Background())
+	return v, err == nil
+}
 
-	mu   sync.Mutex
-	cond *sync.Cond
+// PopContext is Pop giving up when ctx is done
+func (rb *RingBuffer[T]) PopContext(ctx context.Context) (T, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	var zero T
+	if err := rb.wait(ctx, func() bool { return rb.size > 0 }); err != nil {
+		return zero, err
+	}
+
+	// closed and drained
+	if rb.size == 0 {
+		return zero, ErrBufferClosed
+	}
+
+	return rb.pop(), nil
 }
 
-func NewVideoBuffer(capacity int) *VideoBuffer {
-	vb := &VideoBuffer{
-		data: make([]VideoData, capacity),
-		capa: capacity,
+// TryPop removes the oldest item, ok is false when the buffer is empty.
+// Consumers drop what they peeked with it, a seek may have cleared the
+// buffer in between.
+func (rb *RingBuffer[T]) TryPop() (v T, ok bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.size == 0 {
+		return v, false
 	}
-	vb.cond = sync.NewCond(&vb.mu)
-	return vb
+	return rb.pop(), true
 }
 
-func (vb *VideoBuffer) Push(d VideoData) bool {
-	vb.mu.Lock()
-	defer vb.mu.Unlock()
+func (rb *RingBuffer[T]) pop() T {
+	var zero T
 
-	for vb.size == vb.capa {
-		vb.cond.Wait()
+	v := rb.data[rb.r]
+	rb.data[rb.r] = zero
+	rb.r = (rb.r + 1) % rb.capa
+	rb.size--
+	if rb.sizeof != nil {
+		rb.bytes -= rb.sizeof(v)
+	}
+	if rb.durof != nil {
+		rb.dur -= rb.durof(v)
 	}
 
-	vb.data[vb.w] = d
-	vb.w = (vb.w + 1) % vb.capa
-	vb.size++
+	rb.cond.Broadcast()
+	return v
+}
 
-	vb.cond.Signal()
-	return true
+// Len returns the number of items buffered
+func (rb *RingBuffer[T]) Len() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.size
 }
 
-func (vb *VideoBuffer) Peek() *VideoData {
-	vb.mu.Lock()
-	defer vb.mu.Unlock()
-	return &vb.data[vb.r]
+// Cap returns the number of items the buffer holds at most
+func (rb *RingBuffer[T]) Cap() int {
+	return rb.capa
 }
 
-func (vb *VideoBuffer) Pop() {
-	vb.mu.Lock()
-	defer vb.mu.Unlock()
+// Bytes returns the weight of the buffered items, 0 without LimitBytes
+func (rb *RingBuffer[T]) Bytes() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.bytes
+}
+
+// Duration returns the duration of the buffered items in seconds, 0
+// without LimitDuration
+func (rb *RingBuffer[T]) Duration() float64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.dur
+}
 
-	// wait if empty
-	for vb.size == 0 {
-		vb.cond.Wait()
+// Closed reports whether Close was called since the last Reset
+func (rb *RingBuffer[T]) Closed() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.closed
+}
+
+// Drained reports whether the buffer is closed and empty
+func (rb *RingBuffer[T]) Drained() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.closed && rb.size == 0
+}
+
+// Clear drops every item and wakes a blocked producer
+func (rb *RingBuffer[T]) Clear() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	var zero T
+	for i := range rb.size {
+		j := (rb.r + i) % rb.capa
+		if rb.release != nil {
+			rb.release(rb.data[j])
+		}
+	}
+	for i := range rb.data {
+		rb.data[i] = zero
 	}
+	rb.r = 0
+	rb.w = 0
+	rb.size = 0
+	rb.bytes = 0
+	rb.dur = 0
+
+	rb.cond.Broadcast()
+}
+
+// Close wakes every waiter, Push fails from now on while Peek and Pop
+// keep draining what is left
+func (rb *RingBuffer[T]) Close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
 
-	vb.data[vb.r] = VideoData{}
-	vb.r = (vb.r + 1) % vb.capa
-	vb.size--
+	rb.closed = true
+	rb.cond.Broadcast()
+}
+
+// Reset empties the buffer and opens it again after Close
+func (rb *RingBuffer[T]) Reset() {
+	rb.Clear()
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.closed = false
+}
+
+type AudioData struct {
+	PTS float64
+	// how long the samples play, in seconds
+	Duration float64
+	Samples  []byte
+	// the seek generation the samples belong to, see Codec.Serial
+	Serial uint64
+}
+
+// NewAudioBuffer holds about half a second of audio whatever the chunk
+// size is
+func NewAudioBuffer() *RingBuffer[AudioData] {
+	return NewRingBuffer(256, LimitDuration(0.5, func(d AudioData) float64 {
+		return d.Duration
+	}))
+}
 
-	vb.cond.Signal()
+// NewVideoBuffer holds a few frames, fewer when they are 4K
+func NewVideoBuffer() *RingBuffer[VideoData] {
+	return NewRingBuffer(4,
+		LimitBytes(32<<20, func(d VideoData) int {
+			return d.Size()
+		}),
+		OnClear(func(d VideoData) {
+			d.Release()
+		}),
+	)
 }
 
-func (vb *VideoBuffer) Clear() {
-	vb.mu.Lock()
-	defer vb.mu.Unlock()
+type VideoData struct {
+	PTS    float64
+	W      int
+	H      int
+	Format astiav.PixelFormat
+
+	// one entry per plane, Strides are the row sizes in bytes
+	Planes  [][]byte
+	Strides []int
 
-	vb.r = 0
-	vb.w = 0
-	vb.size = 0
+	ColorSpace     astiav.ColorSpace
+	ColorRange     astiav.ColorRange
+	ColorPrimaries astiav.ColorPrimaries
+	ColorTransfer  astiav.ColorTransferCharacteristic
 
-	for i := range vb.data {
-		vb.data[i] = VideoData{}
+	// HDR static metadata, nil when the stream doesn't carry it
+	Mastering    *MasteringDisplay
+	ContentLight *ContentLight
+
+	// the picture is cover art, it is shown whatever the clock says
+	Cover bool
+
+	// the seek generation the frame belongs to, see Codec.Serial
+	Serial uint64
+
+	// the buffer Planes point into and the pool it goes back to
+	buf  []byte
+	pool *FramePool
+}
+
+// Release hands the planes back to the frame pool, neither the frame nor
+// its copies may be used afterwards. It does nothing on frames that
+// don't come from a pool.
+func (vd *VideoData) Release() {
+	if vd.pool != nil && vd.buf != nil {
+		vd.pool.put(vd.buf)
 	}
+	vd.buf = nil
+	vd.pool = nil
+	vd.Planes = nil
+}
 
-	vb.cond.Broadcast()
+// PlaneSize returns the size in pixels of the i-th plane
+func (vd *VideoData) PlaneSize(i int) (int, int) {
+	return planesize(layouts[vd.Format][i], vd.W, vd.H)
+}
+
+// PlaneBPP returns the number of bytes per pixel of the i-th plane
+func (vd *VideoData) PlaneBPP(i int) int {
+	return layouts[vd.Format][i].bpp
+}
+
+// Size returns the number of bytes held by the planes
+func (vd *VideoData) Size() int {
+	n := 0
+	for _, p := range vd.Planes {
+		n += len(p)
+	}
+	return n
 }
@@ -0,0 +1,290 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blocked reports whether fn is still running after a short while
+func blocked(fn func()) (done chan struct{}, isblocked bool) {
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+		return done, false
+	case <-time.After(50 * time.Millisecond):
+		return done, true
+	}
+}
+
+func wait(t *testing.T, done chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("call still blocked")
+	}
+}
+
+func TestRingBufferOrder(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+
+	for round := range 3 {
+		for i := range 3 {
+			if !rb.TryPush(round*10 + i) {
+				t.Fatalf("push %d failed", i)
+			}
+		}
+		if rb.TryPush(99) {
+			t.Fatal("push past capacity succeeded")
+		}
+		if rb.Len() != 3 || rb.Cap() != 3 {
+			t.Fatalf("len %d cap %d", rb.Len(), rb.Cap())
+		}
+
+		for i := range 3 {
+			v, ok := rb.TryPeek()
+			if !ok || v != round*10+i {
+				t.Fatalf("peek got %d %v, want %d", v, ok, round*10+i)
+			}
+			if v, ok := rb.TryPop(); !ok || v != round*10+i {
+				t.Fatalf("pop got %d %v, want %d", v, ok, round*10+i)
+			}
+		}
+	}
+
+	if _, ok := rb.TryPeek(); ok {
+		t.Fatal("peek on empty buffer succeeded")
+	}
+	if _, ok := rb.TryPop(); ok {
+		t.Fatal("pop on empty buffer succeeded")
+	}
+}
+
+func TestRingBufferPushBlocksWhenFull(t *testing.T) {
+	rb := NewRingBuffer[int](1)
+	rb.Push(1)
+
+	done, isblocked := blocked(func() { rb.Push(2) })
+	if !isblocked {
+		t.Fatal("push on full buffer didn't block")
+	}
+
+	if v, ok := rb.TryPop(); !ok || v != 1 {
+		t.Fatalf("pop got %d %v", v, ok)
+	}
+	wait(t, done)
+
+	if v, _ := rb.TryPeek(); v != 2 {
+		t.Fatalf("peek got %d, want 2", v)
+	}
+}
+
+func TestRingBufferPeekBlocksWhenEmpty(t *testing.T) {
+	rb := NewRingBuffer[int](1)
+
+	var got int
+	done, isblocked := blocked(func() { got, _ = rb.Peek() })
+	if !isblocked {
+		t.Fatal("peek on empty buffer didn't block")
+	}
+
+	rb.Push(7)
+	wait(t, done)
+	if got != 7 {
+		t.Fatalf("peek got %d, want 7", got)
+	}
+	if rb.Len() != 1 {
+		t.Fatal("peek removed the item")
+	}
+}
+
+func TestRingBufferCloseDrains(t *testing.T) {
+	rb := NewRingBuffer[int](4)
+	rb.Push(1)
+	rb.Push(2)
+	rb.Close()
+
+	if rb.Push(3) || rb.TryPush(3) {
+		t.Fatal("push after close succeeded")
+	}
+	if err := rb.PushContext(context.Background(), 3); !errors.Is(err, ErrBufferClosed) {
+		t.Fatalf("push context got %v", err)
+	}
+	if rb.Drained() {
+		t.Fatal("drained with items left")
+	}
+
+	for want := 1; want <= 2; want++ {
+		if v, ok := rb.Pop(); !ok || v != want {
+			t.Fatalf("pop got %d %v, want %d", v, ok, want)
+		}
+	}
+
+	if _, ok := rb.Peek(); ok {
+		t.Fatal("peek on drained buffer succeeded")
+	}
+	if _, err := rb.PopContext(context.Background()); !errors.Is(err, ErrBufferClosed) {
+		t.Fatalf("pop context got %v", err)
+	}
+	if !rb.Drained() || !rb.Closed() {
+		t.Fatal("buffer not drained")
+	}
+
+	rb.Reset()
+	if rb.Closed() || !rb.TryPush(1) {
+		t.Fatal("reset didn't open the buffer")
+	}
+}
+
+func TestRingBufferCloseWakesWaiters(t *testing.T) {
+	full := NewRingBuffer[int](1)
+	full.Push(1)
+	empty := NewRingBuffer[int](1)
+
+	var pushed, peeked, popped bool = true, true, true
+	pushdone, b1 := blocked(func() { pushed = full.Push(2) })
+	peekdone, b2 := blocked(func() { _, peeked = empty.Peek() })
+	popdone, b3 := blocked(func() { _, popped = empty.Pop() })
+	if !b1 || !b2 || !b3 {
+		t.Fatal("calls didn't block")
+	}
+
+	full.Close()
+	empty.Close()
+	wait(t, pushdone)
+	wait(t, peekdone)
+	wait(t, popdone)
+
+	if pushed || peeked || popped {
+		t.Fatalf("push %v peek %v pop %v after close", pushed, peeked, popped)
+	}
+}
+
+func TestRingBufferContextCancel(t *testing.T) {
+	rb := NewRingBuffer[int](1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var err error
+	done, isblocked := blocked(func() { _, err = rb.PeekContext(ctx) })
+	if !isblocked {
+		t.Fatal("peek context didn't block")
+	}
+	cancel()
+	wait(t, done)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("peek context got %v", err)
+	}
+
+	rb.Push(1)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := rb.PushContext(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("push context got %v", err)
+	}
+	if rb.Len() != 1 {
+		t.Fatal("cancelled push added an item")
+	}
+}
+
+func TestRingBufferClearWakesProducer(t *testing.T) {
+	rb := NewRingBuffer[int](1)
+	rb.Push(1)
+
+	done, isblocked := blocked(func() { rb.Push(2) })
+	if !isblocked {
+		t.Fatal("push on full buffer didn't block")
+	}
+
+	rb.Clear()
+	wait(t, done)
+	if v, _ := rb.TryPeek(); v != 2 || rb.Len() != 1 {
+		t.Fatalf("got %d with %d items", v, rb.Len())
+	}
+}
+
+func TestRingBufferByteLimit(t *testing.T) {
+	rb := NewRingBuffer(100, LimitBytes(10, func(b []byte) int { return len(b) }))
+
+	if !rb.TryPush(make([]byte, 6)) || !rb.TryPush(make([]byte, 6)) {
+		t.Fatal("push under the limit failed")
+	}
+	if rb.Bytes() != 12 {
+		t.Fatalf("bytes %d, want 12", rb.Bytes())
+	}
+	if rb.TryPush(make([]byte, 1)) {
+		t.Fatal("push over the byte limit succeeded")
+	}
+
+	rb.TryPop()
+	if rb.Bytes() != 6 || !rb.TryPush(make([]byte, 1)) {
+		t.Fatal("pop didn't free room")
+	}
+
+	// a single item bigger than the limit still goes in
+	rb.Clear()
+	if rb.Bytes() != 0 || !rb.TryPush(make([]byte, 64)) {
+		t.Fatal("oversized item refused on an empty buffer")
+	}
+}
+
+func TestRingBufferDurationLimit(t *testing.T) {
+	rb := NewAudioBuffer()
+
+	n := 0
+	for rb.TryPush(AudioData{Duration: 0.02}) {
+		n++
+	}
+	if n != 25 {
+		t.Fatalf("buffered %d chunks of 20ms, want 25", n)
+	}
+	if d := rb.Duration(); d < 0.499 || d > 0.501 {
+		t.Fatalf("duration %f", d)
+	}
+}
+
+func TestRingBufferConcurrent(t *testing.T) {
+	const n = 10000
+	rb := NewRingBuffer[int](8)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range n {
+			if !rb.Push(i) {
+				t.Error("push failed")
+				return
+			}
+		}
+		rb.Close()
+	}()
+
+	// the Peek then TryPop pattern of the player
+	want := 0
+	for {
+		v, ok := rb.Peek()
+		if !ok {
+			break
+		}
+		if v != want {
+			t.Fatalf("got %d, want %d", v, want)
+		}
+		if _, ok := rb.TryPop(); !ok {
+			t.Fatal("pop after peek failed")
+		}
+		want++
+	}
+
+	wg.Wait()
+	if want != n {
+		t.Fatalf("got %d items, want %d", want, n)
+	}
+}
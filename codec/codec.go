@@ -2,51 +2,127 @@ package codec
 
 import (
 	"errors"
-	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 
 	"github.com/asticode/go-astiav"
 )
 
 type AudioMetadata struct {
-	Freq     int
-	Timebase astiav.Rational
+	Freq         int
+	Channels     int
+	SampleFormat astiav.SampleFormat
+	Timebase     astiav.Rational
 }
 
 type VideoMetadata struct {
 	W        int
 	H        int
 	Timebase astiav.Rational
+	// the stream is a single attached picture, usually cover art
+	Cover bool
 }
 
 // ====== CODEC ======
 type Codec struct {
 	ic *astiav.FormatContext
 
-	audio *audiodecoder
-	video *videodecoder
+	audio    *audiodecoder
+	video    *videodecoder
+	subtitle *subtitledecoder
+
+	audioidx    int
+	videoidx    int
+	subtitleidx int
+	seekidx     int
+	tracks      []Track
+
+	// the demuxer queues packets per stream, every decoder drains its
+	// own queue in a goroutine, see Parse
+	audioq    *RingBuffer[packet]
+	videoq    *RingBuffer[packet]
+	subtitleq *RingBuffer[packet]
+
+	// serial+1 of the last EOF marker each decoder drained, 0 for none
+	audioeof    atomic.Uint64
+	videoeof    atomic.Uint64
+	subtitleeof atomic.Uint64
+
+	// kept to set up the decoder again when the audio track changes
+	aformat AudioFormat
+	downmix Downmix
 
-	audioidx int
-	videoidx int
+	// recycles the picture buffers of VideoBuffer
+	pool *FramePool
 
-	AudioBuffer *AudioBuffer
-	VideoBuffer *VideoBuffer
+	// streams Load leaves out
+	novideo bool
+	noaudio bool
+
+	AudioBuffer    *RingBuffer[AudioData]
+	VideoBuffer    *RingBuffer[VideoData]
+	SubtitleBuffer *RingBuffer[SubtitleData]
 
 	timebase astiav.Rational
-	Stopped  bool
+	landing  landing
+
+	// seeks are handed to Parse and done between reads. Every seek bumps
+	// serial, gen is the serial of the data being decoded right now.
+	seekmu  sync.Mutex
+	seekc   chan seekrequest
+	serial  atomic.Uint64
+	gen     uint64
+	parsing bool
 }
 
 func NewCodec() *Codec {
+	pool := NewFramePool()
+
 	return &Codec{
-		ic:          astiav.AllocFormatContext(),
-		audio:       newaudiodecoder(),
-		video:       newvideodecoder(),
-		AudioBuffer: NewAudioBuffer(8),
-		VideoBuffer: NewVideoBuffer(2),
+		pool:           pool,
+		audioidx:       -1,
+		videoidx:       -1,
+		subtitleidx:    -1,
+		seekidx:        -1,
+		seekc:          make(chan seekrequest, 1),
+		audioq:         newpacketqueue(),
+		videoq:         newpacketqueue(),
+		subtitleq:      newpacketqueue(),
+		AudioBuffer:    NewAudioBuffer(),
+		VideoBuffer:    NewVideoBuffer(),
+		SubtitleBuffer: NewSubtitleBuffer(),
 	}
 }
 
+// Load opens path, tearing down whatever was loaded before.
+// Parse must not be running.
 func (c *Codec) Load(path string) (*VideoMetadata, *AudioMetadata, error) {
+	c.release()
+
+	c.ic = astiav.AllocFormatContext()
+	c.audio = newaudiodecoder()
+	c.video = newvideodecoder(c.pool)
+	c.subtitle = newsubtitledecoder()
+	c.audioidx = -1
+	c.videoidx = -1
+	c.subtitleidx = -1
+	c.seekidx = -1
+	c.tracks = nil
+	c.AudioBuffer.Reset()
+	c.VideoBuffer.Reset()
+	c.SubtitleBuffer.Reset()
+
+	// a seek still pending was meant for the previous media
+	select {
+	case <-c.seekc:
+	default:
+	}
+	c.gen = c.serial.Load()
+	c.audioeof.Store(0)
+	c.videoeof.Store(0)
+	c.subtitleeof.Store(0)
+
 	if err := c.ic.OpenInput(path, nil, nil); err != nil {
 		return nil, nil, err
 	}
@@ -55,92 +131,236 @@ func (c *Codec) Load(path string) (*VideoMetadata, *AudioMetadata, error) {
 		return nil, nil, err
 	}
 
+	c.tracks = tracksof(c.ic)
+
 	var err error
 	var am *AudioMetadata = nil
 	var vm *VideoMetadata = nil
 
-	for _, s := range c.ic.Streams() {
-		switch s.CodecParameters().MediaType() {
-		case astiav.MediaTypeVideo:
-			c.videoidx = s.Index()
-
-			err = c.video.load(s)
+	if s, _, serr := c.ic.FindBestStream(astiav.MediaTypeVideo, -1, -1); serr == nil && !c.novideo {
+		vm, err = c.loadvideo(c.video, s)
+	}
 
-			vm = &VideoMetadata{}
-			vm.H = s.CodecParameters().Height()
-			vm.W = s.CodecParameters().Width()
-			vm.Timebase = s.TimeBase()
-		case astiav.MediaTypeAudio:
-			c.audioidx = s.Index()
+	if s, _, serr := c.ic.FindBestStream(astiav.MediaTypeAudio, -1, c.videoidx); serr == nil && err == nil && !c.noaudio {
+		am, err = c.loadaudio(c.audio, s)
+	}
 
-			err = c.audio.load(s)
+	if err == nil && vm == nil && am == nil {
+		err = errors.New("codec: no audio or video stream")
+	}
 
-			am = &AudioMetadata{}
-			am.Freq = s.CodecParameters().SampleRate()
-			am.Timebase = s.TimeBase()
+	// the media plays fine without its subtitles
+	related := c.videoidx
+	if related < 0 {
+		related = c.audioidx
+	}
+	if s, _, serr := c.ic.FindBestStream(astiav.MediaTypeSubtitle, -1, related); serr == nil && err == nil {
+		if lerr := c.loadsubtitle(c.subtitle, s); lerr != nil {
+			log.Println(lerr)
 		}
 	}
 
-	c.timebase = vm.Timebase
+	c.discard()
 
 	return vm, am, err
 }
 
+// SetStreams picks whether Load decodes the video and the audio, the
+// tracks can still be selected by hand afterwards
+func (c *Codec) SetStreams(video, audio bool) {
+	c.novideo = !video
+	c.noaudio = !audio
+}
+
+func (c *Codec) loadvideo(vd *videodecoder, s *astiav.Stream) (*VideoMetadata, error) {
+	if err := vd.load(s); err != nil {
+		return nil, err
+	}
+
+	c.videoidx = s.Index()
+
+	vm := &VideoMetadata{}
+	vm.H = s.CodecParameters().Height()
+	vm.W = s.CodecParameters().Width()
+	vm.Timebase = s.TimeBase()
+	vm.Cover = vd.cover
+
+	c.seekstream()
+
+	return vm, nil
+}
+
+func (c *Codec) loadaudio(ad *audiodecoder, s *astiav.Stream) (*AudioMetadata, error) {
+	if err := ad.load(s); err != nil {
+		return nil, err
+	}
+
+	c.audioidx = s.Index()
+	c.seekstream()
+
+	am := &AudioMetadata{}
+	am.Freq = s.CodecParameters().SampleRate()
+	am.Channels = s.CodecParameters().ChannelLayout().Channels()
+	am.SampleFormat = s.CodecParameters().SampleFormat()
+	am.Timebase = s.TimeBase()
+
+	return am, nil
+}
+
+func (c *Codec) loadsubtitle(sd *subtitledecoder, s *astiav.Stream) error {
+	if err := sd.load(s); err != nil {
+		return err
+	}
+
+	c.subtitleidx = s.Index()
+	return nil
+}
+
+// seekstream picks the stream seeks are done on, video unless it is only
+// cover art
+func (c *Codec) seekstream() {
+	c.seekidx = c.videoidx
+	if c.videoidx < 0 || c.video.cover {
+		c.seekidx = c.audioidx
+	}
+
+	if c.seekidx >= 0 {
+		c.timebase = c.ic.Streams()[c.seekidx].TimeBase()
+	}
+}
+
+// HasAudio reports whether an audio stream is decoded
+func (c *Codec) HasAudio() bool {
+	return c.audioidx >= 0
+}
+
+// HasVideo reports whether a video stream is decoded, cover art included
+func (c *Codec) HasVideo() bool {
+	return c.videoidx >= 0
+}
+
+// PoolStats returns the counters of the video frame pool
+func (c *Codec) PoolStats() PoolStats {
+	return c.pool.Stats()
+}
+
+// SetFrameAllocator makes the video frame pool take its buffers from a,
+// see Allocator
+func (c *Codec) SetFrameAllocator(a Allocator) {
+	c.pool.SetAllocator(a)
+}
+
+// HasSubtitle reports whether a subtitle stream is decoded
+func (c *Codec) HasSubtitle() bool {
+	return c.subtitleidx >= 0
+}
+
+// HasCover reports whether the video stream is a single attached picture
+func (c *Codec) HasCover() bool {
+	return c.videoidx >= 0 && c.video.cover
+}
+
+// discard makes the demuxer skip the streams nobody decodes
+func (c *Codec) discard() {
+	for _, s := range c.ic.Streams() {
+		if idx := s.Index(); idx == c.audioidx || idx == c.videoidx || idx == c.subtitleidx {
+			s.SetDiscard(astiav.DiscardDefault)
+		} else {
+			s.SetDiscard(astiav.DiscardAll)
+		}
+	}
+}
+
 var video_decode_counter int = 0
 var audio_decode_counter int = 0
 
-func (c *Codec) Parse(quit chan struct{}) {
-	pkt := astiav.AllocPacket()
-	defer pkt.Free()
-
-	c.Stopped = false
-
-	for {
-		if stop := func() bool {
-			if err := c.ic.ReadFrame(pkt); err != nil {
-				if !errors.Is(err, astiav.ErrEof) {
-					log.Println(fmt.Errorf("demux: reading frame failed: %w", err))
-				} else {
-					log.Println("End of file")
-				}
-
-				return true
-			}
-
-			defer pkt.Unref()
-
-			switch idx := pkt.StreamIndex(); idx {
-			case c.videoidx:
-				c.video.decode(pkt, c.VideoBuffer)
-				video_decode_counter += 1
-			case c.audioidx:
-				c.audio.decode(pkt, c.AudioBuffer)
-				audio_decode_counter += 1
-			default:
-			}
-
-			return false
-		}(); stop {
-			break
-		}
+// SetAudioFormat sets the format audio is resampled to, it must match
+// the format the audio device was opened with. Sources with more channels
+// than f are folded down with d. Parse must not be running.
+func (c *Codec) SetAudioFormat(f AudioFormat, d Downmix) error {
+	if c.audio == nil {
+		return errors.New("codec: nothing loaded")
 	}
 
-	c.Stopped = true
-	quit <- struct{}{}
+	c.aformat = f
+	c.downmix = d
+	return c.audio.setformat(f, d)
+}
+
+// Parsing reports whether Parse is running and takes seek requests
+func (c *Codec) Parsing() bool {
+	c.seekmu.Lock()
+	defer c.seekmu.Unlock()
+	return c.parsing
+}
+
+// Serial returns the generation of the last seek requested, data with
+// another serial is stale
+func (c *Codec) Serial() uint64 {
+	return c.serial.Load()
 }
 
 func (c *Codec) Duration() int64 {
 	return c.ic.Duration()
 }
 
-func (c *Codec) SeekSecond(second float32) {
+// SeekSecond seeks precisely to second
+func (c *Codec) SeekSecond(second float32) uint64 {
+	return c.Seek(float64(second), SeekPrecise)
+}
+
+// Seek asks Parse to move to second and returns the serial of the data
+// coming after it. When Parse isn't running the seek happens as soon as
+// it starts. The buffers are emptied right away so a decoder blocked on
+// them gets back to its queue, anything stale pushed meanwhile is told
+// apart by its serial.
+func (c *Codec) Seek(second float64, mode SeekMode) uint64 {
+	c.seekmu.Lock()
+	serial := c.serial.Add(1)
+
+	// only the latest request matters
+	select {
+	case <-c.seekc:
+	default:
+	}
+	c.seekc <- seekrequest{second: second, mode: mode, serial: serial}
+	c.seekmu.Unlock()
+
 	c.AudioBuffer.Clear()
 	c.VideoBuffer.Clear()
+	c.SubtitleBuffer.Clear()
 
-	timestamp := int64(second / float32(c.timebase.Float64()))
-	err := c.ic.SeekFrame(c.videoidx, timestamp, astiav.NewSeekFlags(astiav.SeekFlagBackward))
+	return serial
+}
+
+// Close releases the demuxer and the decoders, Parse must not be running
+func (c *Codec) Close() {
+	c.AudioBuffer.Close()
+	c.VideoBuffer.Close()
+	c.SubtitleBuffer.Close()
+
+	c.release()
+}
+
+func (c *Codec) release() {
+	if c.audio != nil {
+		c.audio.close()
+		c.audio = nil
+	}
+
+	if c.video != nil {
+		c.video.close()
+		c.video = nil
+	}
+
+	if c.subtitle != nil {
+		c.subtitle.close()
+		c.subtitle = nil
+	}
 
-	if err != nil {
-		log.Println(err)
+	if c.ic != nil {
+		c.ic.CloseInput()
+		c.ic.Free()
+		c.ic = nil
 	}
 }
@@ -0,0 +1,307 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+)
+
+// Parse demuxes until EOF, a read error or quit is closed. Packets go to
+// a queue per stream and the audio, video and subtitle decoders drain
+// them in goroutines of their own, so a slow stream doesn't starve the
+// others. Reading ahead stops once every stream has enough queued.
+//
+// On EOF the decoders are drained and the buffers closed so consumers
+// can drain what is left, unless a seek came in meanwhile.
+func (c *Codec) Parse(quit <-chan struct{}) {
+	c.seekmu.Lock()
+	c.parsing = true
+	c.seekmu.Unlock()
+
+	c.audioq.Reset()
+	c.videoq.Reset()
+	c.subtitleq.Reset()
+
+	var wg sync.WaitGroup
+	c.startdecoders(&wg)
+
+	eof := c.demux(quit)
+
+	for _, q := range []*RingBuffer[packet]{c.audioq, c.videoq, c.subtitleq} {
+		flushqueue(q)
+		q.Close()
+	}
+	wg.Wait()
+
+	c.seekmu.Lock()
+	c.parsing = false
+	c.seekmu.Unlock()
+
+	if eof {
+		c.AudioBuffer.Close()
+		c.VideoBuffer.Close()
+		c.SubtitleBuffer.Close()
+	}
+}
+
+func (c *Codec) startdecoders(wg *sync.WaitGroup) {
+	run := func(q *RingBuffer[packet], eof *atomic.Uint64, decode func(*astiav.Packet, uint64), reset func(packet)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decodeloop(q, eof, decode, reset)
+		}()
+	}
+
+	if c.audioidx >= 0 {
+		ad := c.audio
+		run(c.audioq, &c.audioeof, func(pkt *astiav.Packet, serial uint64) {
+			ad.decode(pkt, c.AudioBuffer, serial)
+		}, func(p packet) {
+			flush(ad.ctx)
+			ad.gate.arm(p.seek.second, p.seek.mode, p.land)
+		})
+	}
+
+	if c.videoidx >= 0 {
+		vd := c.video
+		run(c.videoq, &c.videoeof, func(pkt *astiav.Packet, serial uint64) {
+			vd.decode(pkt, c.VideoBuffer, serial)
+		}, func(p packet) {
+			flush(vd.ctx)
+			vd.gate.arm(p.seek.second, p.seek.mode, p.land)
+		})
+	}
+
+	if c.subtitleidx >= 0 {
+		sd := c.subtitle
+		run(c.subtitleq, &c.subtitleeof, func(pkt *astiav.Packet, serial uint64) {
+			sd.decode(pkt, c.SubtitleBuffer, serial)
+		}, func(p packet) {
+			flush(sd.ctx)
+			sd.gate.arm(p.seek.second, p.seek.mode, nil)
+		})
+	}
+}
+
+// decodeloop feeds one decoder from q until q is closed and drained
+func decodeloop(q *RingBuffer[packet], eof *atomic.Uint64, decode func(*astiav.Packet, uint64), reset func(packet)) {
+	for {
+		p, ok := q.Pop()
+		if !ok {
+			return
+		}
+
+		switch {
+		case p.seek != nil:
+			reset(p)
+		case p.eof:
+			// a nil packet drains what the decoder still holds
+			decode(nil, p.serial)
+			eof.Store(p.serial + 1)
+		default:
+			decode(p.pkt, p.serial)
+			p.free()
+		}
+	}
+}
+
+// demux reads packets into the queues, it reports whether it stopped at
+// the end of the media rather than on quit
+func (c *Codec) demux(quit <-chan struct{}) bool {
+	for {
+		select {
+		case <-quit:
+			return false
+		case req := <-c.seekc:
+			c.seek(req)
+		default:
+		}
+
+		// enough read ahead, let the decoders catch up
+		if c.enough() {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+
+		pkt := astiav.AllocPacket()
+		if err := c.ic.ReadFrame(pkt); err != nil {
+			pkt.Free()
+			if !errors.Is(err, astiav.ErrEof) {
+				log.Println(fmt.Errorf("demux: reading frame failed: %w", err))
+			} else {
+				log.Println("End of file")
+			}
+
+			if c.drain(quit) {
+				return true
+			}
+			continue
+		}
+
+		c.queue(pkt)
+	}
+}
+
+// queue hands pkt to the queue of its stream, packets of streams nobody
+// decodes are dropped
+func (c *Codec) queue(pkt *astiav.Packet) {
+	var q *RingBuffer[packet]
+	var tb astiav.Rational
+
+	switch pkt.StreamIndex() {
+	case c.videoidx:
+		q, tb = c.videoq, c.video.timebase
+		video_decode_counter += 1
+	case c.audioidx:
+		q, tb = c.audioq, c.audio.tb
+		audio_decode_counter += 1
+	case c.subtitleidx:
+		q, tb = c.subtitleq, c.subtitle.timebase
+	default:
+		pkt.Free()
+		return
+	}
+
+	ok := q.Push(packet{
+		pkt:      pkt,
+		serial:   c.gen,
+		duration: packetduration(pkt, tb),
+		size:     pkt.Size(),
+	})
+	if !ok {
+		pkt.Free()
+	}
+}
+
+// enough reports whether the queues hold enough to stop reading for a
+// while: the queued duration of every audio and video stream reached
+// queueduration, or the queues are about to hit their size limits. The
+// subtitle queue never stops the reading, its decoder doesn't wait on
+// the clock and keeps it drained.
+func (c *Codec) enough() bool {
+	queues := []*RingBuffer[packet]{c.audioq, c.videoq}
+	timed := []bool{c.audioidx >= 0, c.videoidx >= 0 && !c.video.cover}
+
+	bytes, short := 0, false
+	for i, q := range queues {
+		if q.Len() >= q.Cap()-1 {
+			return true
+		}
+		bytes += q.Bytes()
+		if timed[i] && q.Duration() < queueduration {
+			short = true
+		}
+	}
+
+	return bytes >= queuebytes || !short
+}
+
+// drain queues the EOF markers and waits for every decoder to be done
+// with them. It returns false when a seek came in meanwhile, the demux
+// loop then goes on.
+func (c *Codec) drain(quit <-chan struct{}) bool {
+	type stream struct {
+		q   *RingBuffer[packet]
+		eof *atomic.Uint64
+	}
+
+	var streams []stream
+	if c.audioidx >= 0 {
+		streams = append(streams, stream{c.audioq, &c.audioeof})
+	}
+	if c.videoidx >= 0 {
+		streams = append(streams, stream{c.videoq, &c.videoeof})
+	}
+	if c.subtitleidx >= 0 {
+		streams = append(streams, stream{c.subtitleq, &c.subtitleeof})
+	}
+
+	for _, s := range streams {
+		s.q.Push(packet{serial: c.gen, eof: true})
+	}
+
+	for {
+		select {
+		case <-quit:
+			return true
+		case req := <-c.seekc:
+			c.seek(req)
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		drained := true
+		for _, s := range streams {
+			if s.eof.Load() != c.gen+1 {
+				drained = false
+			}
+		}
+		if !drained {
+			continue
+		}
+
+		return !c.pending()
+	}
+}
+
+// pending stops parsing unless a seek is waiting, taken together with
+// Seek queueing so a request is either seen by this Parse or the next
+func (c *Codec) pending() bool {
+	c.seekmu.Lock()
+	defer c.seekmu.Unlock()
+
+	if len(c.seekc) > 0 {
+		return true
+	}
+	c.parsing = false
+	return false
+}
+
+// seek runs in the demux loop between two reads. It moves to the
+// keyframe before the target, drops the queued packets and queues a
+// marker for each decoder to flush itself. A precise seek then drops
+// what decodes before the target, see Landed for where it ended up.
+func (c *Codec) seek(req seekrequest) {
+	c.gen = req.serial
+	c.landing.take()
+
+	if c.seekidx < 0 {
+		return
+	}
+
+	timestamp := int64(req.second / c.timebase.Float64())
+	err := c.ic.SeekFrame(c.seekidx, timestamp, astiav.NewSeekFlags(astiav.SeekFlagBackward))
+	if err != nil {
+		log.Println(fmt.Errorf("codec: seeking failed: %w", err))
+		return
+	}
+
+	flushqueue(c.audioq)
+	flushqueue(c.videoq)
+	flushqueue(c.subtitleq)
+
+	// the stream seeks are done on tells where the seek landed
+	var aland, vland *landing
+	if c.seekidx == c.videoidx {
+		vland = &c.landing
+	} else {
+		aland = &c.landing
+	}
+
+	if c.audioidx >= 0 {
+		c.audioq.Push(packet{serial: req.serial, seek: &req, land: aland})
+	}
+	// cover art is sent once, there is nothing to skip to
+	if c.videoidx >= 0 && !c.video.cover {
+		c.videoq.Push(packet{serial: req.serial, seek: &req, land: vland})
+	}
+	if c.subtitleidx >= 0 {
+		c.subtitleq.Push(packet{serial: req.serial, seek: &req})
+	}
+}
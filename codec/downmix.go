@@ -0,0 +1,245 @@
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/asticode/go-astiav"
+)
+
+type DownmixMatrix int
+
+const (
+	// ITU-R BS.775, centre and surrounds folded in at -3 dB
+	DownmixITU DownmixMatrix = iota
+	// Dolby Surround compatible Lt/Rt, surrounds folded in out of phase
+	DownmixDolby
+)
+
+func (m DownmixMatrix) String() string {
+	switch m {
+	case DownmixITU:
+		return "itu"
+	case DownmixDolby:
+		return "dolby"
+	}
+	return "unknown"
+}
+
+// Downmix controls how sources with more channels than the audio device
+// are folded down
+type Downmix struct {
+	Matrix DownmixMatrix
+	// dB added to the centre channel to lift dialogue, 0 keeps the
+	// standard -3 dB
+	CenterBoost float64
+	// fold the LFE channel into the main channels when the output has none
+	LFE bool
+}
+
+type speaker int
+
+const (
+	spkFL speaker = iota
+	spkFR
+	spkFC
+	spkLFE
+	spkBL
+	spkBR
+	spkSL
+	spkSR
+	spkBC
+)
+
+// speakers is the channel order of the layouts returned by channellayout
+var speakers = map[int][]speaker{
+	1: {spkFC},
+	2: {spkFL, spkFR},
+	3: {spkFL, spkFR, spkLFE},
+	4: {spkFL, spkFR, spkBL, spkBR},
+	6: {spkFL, spkFR, spkFC, spkLFE, spkSL, spkSR},
+	7: {spkFL, spkFR, spkFC, spkLFE, spkBC, spkSL, spkSR},
+	8: {spkFL, spkFR, spkFC, spkLFE, spkBL, spkBR, spkSL, spkSR},
+}
+
+// SupportedChannels returns the closest channel count with a known
+// layout that can hold n channels
+func SupportedChannels(n int) int {
+	switch {
+	case n <= 0:
+		return 2
+	case n == 5:
+		return 6
+	case n > 8:
+		return 8
+	}
+	return n
+}
+
+const sqrt1_2 = math.Sqrt2 / 2
+
+// mixer folds interleaved float samples from in channels down to out
+type mixer struct {
+	in, out int
+	format  astiav.SampleFormat
+	matrix  [][]float32
+}
+
+func newmixer(in, out int, format astiav.SampleFormat, d Downmix) *mixer {
+	inspk, outspk := speakers[in], speakers[out]
+
+	idx := func(s speaker) int {
+		for i, o := range outspk {
+			if o == s {
+				return i
+			}
+		}
+		return -1
+	}
+	has := func(s ...speaker) bool {
+		for _, o := range s {
+			if idx(o) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	m := make([][]float64, out)
+	for i := range m {
+		m[i] = make([]float64, in)
+	}
+	add := func(s speaker, j int, g float64) {
+		if i := idx(s); i >= 0 {
+			m[i][j] += g
+		}
+	}
+
+	// surround to the fronts, ITU keeps the sides, Dolby encodes them
+	// out of phase so a decoder can steer them back
+	surround := func(j int, left bool, g float64) {
+		switch {
+		case !has(spkFL, spkFR):
+			add(spkFC, j, g)
+		case d.Matrix == DownmixDolby:
+			add(spkFL, j, -g*sqrt1_2)
+			add(spkFR, j, g*sqrt1_2)
+		case left:
+			add(spkFL, j, g)
+		default:
+			add(spkFR, j, g)
+		}
+	}
+
+	center := sqrt1_2 * math.Pow(10, d.CenterBoost/20)
+
+	// side and back surrounds stand in for each other
+	pair := map[speaker]speaker{
+		spkSL: spkBL, spkBL: spkSL,
+		spkSR: spkBR, spkBR: spkSR,
+	}
+
+	for j, s := range inspk {
+		if idx(s) >= 0 {
+			add(s, j, 1)
+			continue
+		}
+
+		switch s {
+		case spkFL, spkFR:
+			add(spkFC, j, sqrt1_2)
+		case spkFC:
+			add(spkFL, j, center)
+			add(spkFR, j, center)
+		case spkLFE:
+			if !d.LFE {
+				continue
+			}
+			if has(spkFL, spkFR) {
+				add(spkFL, j, sqrt1_2)
+				add(spkFR, j, sqrt1_2)
+			} else {
+				add(spkFC, j, sqrt1_2)
+			}
+		case spkSL, spkBL, spkSR, spkBR:
+			if has(pair[s]) {
+				add(pair[s], j, 1)
+			} else {
+				surround(j, s == spkSL || s == spkBL, sqrt1_2)
+			}
+		case spkBC:
+			switch {
+			case has(spkBL, spkBR):
+				add(spkBL, j, sqrt1_2)
+				add(spkBR, j, sqrt1_2)
+			case has(spkSL, spkSR):
+				add(spkSL, j, sqrt1_2)
+				add(spkSR, j, sqrt1_2)
+			default:
+				surround(j, true, 0.5)
+				if d.Matrix != DownmixDolby {
+					surround(j, false, 0.5)
+				}
+			}
+		}
+	}
+
+	// scale so that no output can clip
+	peak := 1.0
+	for _, row := range m {
+		sum := 0.0
+		for _, g := range row {
+			sum += math.Abs(g)
+		}
+		peak = max(peak, sum)
+	}
+
+	matrix := make([][]float32, out)
+	for i, row := range m {
+		matrix[i] = make([]float32, in)
+		for j, g := range row {
+			matrix[i][j] = float32(g / peak)
+		}
+	}
+
+	return &mixer{
+		in:     in,
+		out:    out,
+		format: format,
+		matrix: matrix,
+	}
+}
+
+// mix takes interleaved float samples and returns them folded down in
+// the output sample format
+func (mx *mixer) mix(samples []byte) []byte {
+	frames := len(samples) / (4 * mx.in)
+	bps := mx.format.BytesPerSample()
+	dst := make([]byte, frames*mx.out*bps)
+
+	src := make([]float32, mx.in)
+	for f := 0; f < frames; f++ {
+		for j := range src {
+			off := (f*mx.in + j) * 4
+			src[j] = math.Float32frombits(binary.LittleEndian.Uint32(samples[off:]))
+		}
+
+		for i, row := range mx.matrix {
+			var v float32
+			for j, g := range row {
+				v += g * src[j]
+			}
+			v = min(max(v, -1), 1)
+
+			off := (f*mx.out + i) * bps
+			switch mx.format {
+			case astiav.SampleFormatS16:
+				binary.LittleEndian.PutUint16(dst[off:], uint16(int16(v*32767)))
+			default:
+				binary.LittleEndian.PutUint32(dst[off:], math.Float32bits(v))
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"github.com/asticode/go-astiav"
+)
+
+// alignment of every plane row in VideoData, 1 keeps the rows tightly packed
+const videoalign = 1
+
+// pixel format frames are converted to when the renderer can't draw them
+const fallbackformat = astiav.PixelFormatYuv420P
+
+type planeinfo struct {
+	// log2 of the horizontal and vertical subsampling
+	wshift, hshift int
+	// bytes per pixel in the plane
+	bpp int
+}
+
+// layouts lists the pixel formats the renderer draws without conversion
+var layouts = map[astiav.PixelFormat][]planeinfo{
+	astiav.PixelFormatYuv420P:     {{0, 0, 1}, {1, 1, 1}, {1, 1, 1}},
+	astiav.PixelFormatYuvj420P:    {{0, 0, 1}, {1, 1, 1}, {1, 1, 1}},
+	astiav.PixelFormatYuv422P:     {{0, 0, 1}, {1, 0, 1}, {1, 0, 1}},
+	astiav.PixelFormatYuvj422P:    {{0, 0, 1}, {1, 0, 1}, {1, 0, 1}},
+	astiav.PixelFormatYuv444P:     {{0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+	astiav.PixelFormatYuvj444P:    {{0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+	astiav.PixelFormatYuv420P10Le: {{0, 0, 2}, {1, 1, 2}, {1, 1, 2}},
+	astiav.PixelFormatNv12:        {{0, 0, 1}, {1, 1, 2}},
+	astiav.PixelFormatP010Le:      {{0, 0, 2}, {1, 1, 4}},
+}
+
+func renderable(format astiav.PixelFormat) bool {
+	_, ok := layouts[format]
+	return ok
+}
+
+func planesize(p planeinfo, w, h int) (int, int) {
+	// rounded up the same way libav does for odd sizes
+	return -((-w) >> p.wshift), -((-h) >> p.hshift)
+}
+
+// splitplanes cuts a buffer filled by Frame.Data().Bytes(align) into its planes
+func splitplanes(format astiav.PixelFormat, w, h int, buf []byte, align int) ([][]byte, []int) {
+	layout := layouts[format]
+
+	planes := make([][]byte, 0, len(layout))
+	strides := make([]int, 0, len(layout))
+
+	start := 0
+	for _, p := range layout {
+		pw, ph := planesize(p, w, h)
+		stride := (pw*p.bpp + align - 1) / align * align
+
+		end := min(start+stride*ph, len(buf))
+		planes = append(planes, buf[start:end])
+		strides = append(strides, stride)
+		start = end
+	}
+
+	return planes, strides
+}
@@ -0,0 +1,72 @@
+package codec
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/frame.h>
+//#include <libavutil/mastering_display_metadata.h>
+import "C"
+import (
+	"unsafe"
+
+	"github.com/asticode/go-astiav"
+)
+
+// MasteringDisplay is the SMPTE ST 2086 static metadata of the display
+// the content was graded on
+type MasteringDisplay struct {
+	// CIE 1931 xy of the red, green and blue primaries
+	Primaries  [3][2]float64
+	WhitePoint [2]float64
+
+	// in cd/m², zero when unknown
+	MinLuminance float64
+	MaxLuminance float64
+}
+
+// ContentLight is the CTA-861.3 content light level, in cd/m²
+type ContentLight struct {
+	MaxCLL  int
+	MaxFALL int
+}
+
+// astiav doesn't wrap these side data types yet, read them from the
+// AVFrame directly
+func masteringof(f *astiav.Frame) *MasteringDisplay {
+	cf := (*C.AVFrame)(f.UnsafePointer())
+
+	sd := C.av_frame_get_side_data(cf, C.AV_FRAME_DATA_MASTERING_DISPLAY_METADATA)
+	if sd == nil {
+		return nil
+	}
+	md := (*C.AVMasteringDisplayMetadata)(unsafe.Pointer(sd.data))
+
+	m := &MasteringDisplay{}
+	if md.has_primaries != 0 {
+		for i := range 3 {
+			m.Primaries[i][0] = float64(C.av_q2d(md.display_primaries[i][0]))
+			m.Primaries[i][1] = float64(C.av_q2d(md.display_primaries[i][1]))
+		}
+		m.WhitePoint[0] = float64(C.av_q2d(md.white_point[0]))
+		m.WhitePoint[1] = float64(C.av_q2d(md.white_point[1]))
+	}
+	if md.has_luminance != 0 {
+		m.MinLuminance = float64(C.av_q2d(md.min_luminance))
+		m.MaxLuminance = float64(C.av_q2d(md.max_luminance))
+	}
+
+	return m
+}
+
+func contentlightof(f *astiav.Frame) *ContentLight {
+	cf := (*C.AVFrame)(f.UnsafePointer())
+
+	sd := C.av_frame_get_side_data(cf, C.AV_FRAME_DATA_CONTENT_LIGHT_LEVEL)
+	if sd == nil {
+		return nil
+	}
+	cl := (*C.AVContentLightMetadata)(unsafe.Pointer(sd.data))
+
+	return &ContentLight{
+		MaxCLL:  int(cl.MaxCLL),
+		MaxFALL: int(cl.MaxFALL),
+	}
+}
@@ -0,0 +1,66 @@
+package codec
+
+import (
+	"github.com/asticode/go-astiav"
+)
+
+const (
+	// the demuxer reads ahead until every stream has this many seconds
+	// of packets queued
+	queueduration = 1.0
+	// or until the queues hold this many bytes, whatever comes first
+	queuebytes = 16 << 20
+	// a safety net for streams without packet durations, a single queue
+	// never holds more than this
+	queuepackets = 4096
+)
+
+// packet is what the demuxer hands to a decoder goroutine. Besides data
+// packets it carries the markers of a seek and of the end of file.
+type packet struct {
+	pkt    *astiav.Packet
+	serial uint64
+
+	// in seconds, from the packet duration
+	duration float64
+	size     int
+
+	// set on the marker queued after a seek, the decoder flushes
+	seek *seekrequest
+	land *landing
+
+	// set on the marker queued at EOF, the decoder drains
+	eof bool
+}
+
+func (p packet) free() {
+	if p.pkt != nil {
+		p.pkt.Free()
+	}
+}
+
+func newpacketqueue() *RingBuffer[packet] {
+	return NewRingBuffer(queuepackets,
+		LimitBytes(queuebytes, func(p packet) int { return p.size }),
+		LimitDuration(0, func(p packet) float64 { return p.duration }),
+	)
+}
+
+// flushqueue drops everything queued in q, freeing the packets
+func flushqueue(q *RingBuffer[packet]) {
+	for {
+		p, ok := q.TryPop()
+		if !ok {
+			return
+		}
+		p.free()
+	}
+}
+
+// packetduration returns how long pkt plays in seconds, 0 when unknown
+func packetduration(pkt *astiav.Packet, tb astiav.Rational) float64 {
+	if pkt.Duration() <= 0 || tb.Num() == 0 {
+		return 0
+	}
+	return float64(pkt.Duration()) * tb.Float64()
+}
@@ -0,0 +1,128 @@
+package codec
+
+import "sync"
+
+// buffers kept per size, a few frames in flight plus the one on screen
+const poolsize = 8
+
+// FramePool recycles the buffers decoded pictures are copied into, so
+// the video decoder doesn't allocate a new one for every frame
+type FramePool struct {
+	mu    sync.Mutex
+	free  map[int][][]byte
+	stats PoolStats
+
+	// buffers come from there first when set
+	alloc Allocator
+}
+
+// Allocator hands a FramePool buffers from memory of its own, the
+// renderer's mapped upload buffers for one, so pictures are copied
+// straight where they are read from. Both calls may come from any
+// goroutine.
+type Allocator interface {
+	// Alloc returns a buffer of size bytes, false when none is free
+	Alloc(size int) ([]byte, bool)
+	// Free takes back buf and reports whether Alloc handed it out
+	Free(buf []byte) bool
+}
+
+// PoolStats counts what went through a FramePool
+type PoolStats struct {
+	// buffers handed out, either reused from the pool, allocated or
+	// taken from the Allocator
+	Gets   uint64
+	Reuses uint64
+	Allocs uint64
+	Mapped uint64
+	// bytes allocated over the pool lifetime
+	AllocatedBytes uint64
+
+	// buffers given back, Dropped ones didn't fit in the pool anymore
+	Returns uint64
+	Dropped uint64
+
+	// buffers handed out and not returned yet, and buffers waiting in
+	// the pool
+	InUse int
+	Free  int
+}
+
+func NewFramePool() *FramePool {
+	return &FramePool{
+		free: make(map[int][][]byte),
+	}
+}
+
+// SetAllocator makes the pool take its buffers from a first, nil goes
+// back to Go memory only. Buffers a handed out keep going back to it.
+func (p *FramePool) SetAllocator(a Allocator) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.alloc = a
+}
+
+// get returns a buffer of size bytes, its content is undefined
+func (p *FramePool) get(size int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the resolution changed, the old buffers won't be asked for again
+	for s, bufs := range p.free {
+		if s != size {
+			p.stats.Free -= len(bufs)
+			delete(p.free, s)
+		}
+	}
+
+	p.stats.Gets++
+	p.stats.InUse++
+
+	if p.alloc != nil {
+		if buf, ok := p.alloc.Alloc(size); ok {
+			p.stats.Mapped++
+			return buf
+		}
+	}
+
+	if bufs := p.free[size]; len(bufs) > 0 {
+		buf := bufs[len(bufs)-1]
+		p.free[size] = bufs[:len(bufs)-1]
+		p.stats.Reuses++
+		p.stats.Free--
+		return buf
+	}
+
+	p.stats.Allocs++
+	p.stats.AllocatedBytes += uint64(size)
+	return make([]byte, size)
+}
+
+// put gives buf back, it must not be used afterwards
+func (p *FramePool) put(buf []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Returns++
+	p.stats.InUse--
+
+	if p.alloc != nil && p.alloc.Free(buf) {
+		return
+	}
+
+	size := len(buf)
+	if len(p.free[size]) >= poolsize {
+		p.stats.Dropped++
+		return
+	}
+
+	p.free[size] = append(p.free[size], buf)
+	p.stats.Free++
+}
+
+// Stats returns a snapshot of the pool counters
+func (p *FramePool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"fmt"
+	"testing"
+)
+
+// keeps the unpooled buffers alive so the allocations aren't optimized away
+var sink []byte
+
+func BenchmarkFramePool(b *testing.B) {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		// 3840x2160, 4:2:0 with one and two bytes per sample
+		{"4K-yuv420p", 3840 * 2160 * 3 / 2},
+		{"4K-p010", 3840 * 2160 * 3},
+	}
+
+	// frames held at once, the video buffer plus the one on screen
+	const inflight = 5
+
+	for _, s := range sizes {
+		b.Run(fmt.Sprintf("%s/pooled", s.name), func(b *testing.B) {
+			p := NewFramePool()
+			bufs := make([][]byte, inflight)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(s.size))
+			b.ResetTimer()
+
+			for i := range b.N {
+				n := i % inflight
+				if bufs[n] != nil {
+					p.put(bufs[n])
+				}
+				bufs[n] = p.get(s.size)
+				bufs[n][0] = byte(i)
+			}
+
+			b.StopTimer()
+			st := p.Stats()
+			b.ReportMetric(float64(st.Reuses), "hits")
+			b.ReportMetric(float64(st.Allocs), "pool-allocs")
+			b.ReportMetric(float64(st.Reuses)/float64(st.Gets), "hits/get")
+		})
+
+		b.Run(fmt.Sprintf("%s/unpooled", s.name), func(b *testing.B) {
+			bufs := make([][]byte, inflight)
+
+			b.ReportAllocs()
+			b.SetBytes(int64(s.size))
+			b.ResetTimer()
+
+			for i := range b.N {
+				n := i % inflight
+				bufs[n] = make([]byte, s.size)
+				bufs[n][0] = byte(i)
+			}
+
+			b.StopTimer()
+			sink = bufs[0]
+			b.ReportMetric(0, "hits")
+			b.ReportMetric(float64(b.N), "pool-allocs")
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package codec
+
+//#cgo pkg-config: libavcodec
+//#include <libavcodec/avcodec.h>
+import "C"
+import (
+	"sync"
+
+	"github.com/asticode/go-astiav"
+)
+
+// SeekMode tells how exact a seek has to be
+type SeekMode int
+
+const (
+	// SeekPrecise decodes and drops frames up to the requested time
+	SeekPrecise SeekMode = iota
+	// SeekKeyframe stops at the keyframe before the requested time, fast
+	// enough for scrubbing
+	SeekKeyframe
+)
+
+// astiav doesn't wrap avcodec_flush_buffers yet
+func flush(ctx *astiav.CodecContext) {
+	if ctx == nil {
+		return
+	}
+	C.avcodec_flush_buffers((*C.AVCodecContext)(ctx.UnsafePointer()))
+}
+
+type seekrequest struct {
+	second float64
+	mode   SeekMode
+	serial uint64
+}
+
+// landing holds where the last seek landed until it is taken
+type landing struct {
+	mu    sync.Mutex
+	pos   float64
+	fresh bool
+}
+
+func (l *landing) set(pos float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pos = pos
+	l.fresh = true
+}
+
+func (l *landing) take() (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fresh := l.fresh
+	l.fresh = false
+	return l.pos, fresh
+}
+
+// seekgate sits in a decoder after a seek, it drops what decodes before
+// a precise target and reports the first frame let through
+type seekgate struct {
+	drop   bool
+	target float64
+	land   *landing
+}
+
+// arm resets the gate for a seek to target, land is nil for the stream
+// that doesn't report the landing
+func (g *seekgate) arm(target float64, mode SeekMode, land *landing) {
+	g.drop = mode == SeekPrecise
+	g.target = target
+	g.land = land
+}
+
+// pass reports whether a frame spanning start to end is kept
+func (g *seekgate) pass(start, end float64) bool {
+	if g.drop {
+		if end <= g.target && start < g.target {
+			return false
+		}
+		g.drop = false
+		start = max(start, g.target)
+	}
+
+	if g.land != nil {
+		g.land.set(start)
+		g.land = nil
+	}
+	return true
+}
+
+// Landed returns the position the last seek landed on, once, after the
+// first frame past it was decoded
+func (c *Codec) Landed() (float64, bool) {
+	return c.landing.take()
+}
@@ -0,0 +1,199 @@
+package codec
+
+//#cgo pkg-config: libavcodec
+//#include <stdlib.h>
+//#include <libavcodec/avcodec.h>
+//
+//static int decode_subtitle(AVCodecContext *ctx, AVSubtitle *sub, int *got, uint8_t *data, int size, int64_t pts, int64_t duration) {
+//	AVPacket *pkt = av_packet_alloc();
+//	if (!pkt) {
+//		return AVERROR(ENOMEM);
+//	}
+//	pkt->data = data;
+//	pkt->size = size;
+//	pkt->pts = pts;
+//	pkt->duration = duration;
+//	int ret = avcodec_decode_subtitle2(ctx, sub, got, pkt);
+//	pkt->data = NULL;
+//	pkt->size = 0;
+//	av_packet_free(&pkt);
+//	return ret;
+//}
+//
+//static AVSubtitleRect *subtitle_rect(AVSubtitle *sub, unsigned i) {
+//	return sub->rects[i];
+//}
+import "C"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astikit"
+)
+
+// SubtitleData is a text subtitle shown from Start to End, in seconds.
+// Bitmap subtitles are not supported.
+type SubtitleData struct {
+	Start float64
+	End   float64
+	Text  string
+	// the seek generation the subtitle belongs to, see Codec.Serial
+	Serial uint64
+}
+
+// NewSubtitleBuffer holds the next subtitles, enough for the busy signs
+// and karaoke tracks read well ahead of their time
+func NewSubtitleBuffer() *RingBuffer[SubtitleData] {
+	return NewRingBuffer[SubtitleData](256)
+}
+
+// how long a subtitle without an end time stays on screen, in seconds
+const subtitlefallback = 4.0
+
+type subtitledecoder struct {
+	closer *astikit.Closer
+	ctx    *astiav.CodecContext
+
+	timebase astiav.Rational
+	gate     seekgate
+}
+
+func newsubtitledecoder() *subtitledecoder {
+	return &subtitledecoder{
+		closer: astikit.NewCloser(),
+	}
+}
+
+func (sd *subtitledecoder) close() {
+	sd.closer.Close()
+}
+
+func (sd *subtitledecoder) load(stream *astiav.Stream) error {
+	codec := astiav.FindDecoder(stream.CodecParameters().CodecID())
+	if codec == nil {
+		return errors.New("subtitle decoder: codec is nil")
+	}
+
+	sd.ctx = astiav.AllocCodecContext(codec)
+	if sd.ctx == nil {
+		return errors.New("subtitle decoder: codec context is nil")
+	}
+	sd.closer.Add(sd.ctx.Free)
+
+	err := stream.CodecParameters().ToCodecContext(sd.ctx)
+	if err != nil {
+		return fmt.Errorf("subtitle decoder: updating codec context failed: %w", err)
+	}
+
+	err = sd.ctx.Open(codec, nil)
+	if err != nil {
+		return fmt.Errorf("subtitle decoder: opening codec context failed: %w", err)
+	}
+
+	sd.timebase = stream.TimeBase()
+	return nil
+}
+
+// astiav doesn't wrap subtitle decoding yet, the packet is handed over
+// to avcodec_decode_subtitle2 by hand
+func (sd *subtitledecoder) decode(pkt *astiav.Packet, sBuffer *RingBuffer[SubtitleData], serial uint64) error {
+	if sd.ctx == nil {
+		return errors.New("decoder context is nil")
+	}
+
+	// subtitle decoders keep nothing to drain
+	if pkt == nil {
+		return nil
+	}
+
+	data := pkt.Data()
+	if len(data) == 0 {
+		return nil
+	}
+	cdata := C.CBytes(data)
+	defer C.free(cdata)
+
+	var sub C.AVSubtitle
+	var got C.int
+	ret := C.decode_subtitle(
+		(*C.AVCodecContext)(sd.ctx.UnsafePointer()),
+		&sub, &got,
+		(*C.uint8_t)(cdata), C.int(len(data)),
+		C.int64_t(pkt.Pts()), C.int64_t(pkt.Duration()),
+	)
+	if ret < 0 {
+		log.Println(fmt.Errorf("subtitle decode: decoding packet failed: %d", int(ret)))
+		return nil
+	}
+	if got == 0 {
+		return nil
+	}
+	defer C.avsubtitle_free(&sub)
+
+	pts := float64(pkt.Pts()) * sd.timebase.Float64()
+	start := pts + float64(sub.start_display_time)/1000
+	end := pts + float64(sub.end_display_time)/1000
+	if sub.end_display_time == 0 {
+		end = pts + packetduration(pkt, sd.timebase)
+	}
+	if end <= start {
+		end = start + subtitlefallback
+	}
+
+	var lines []string
+	for i := range uint(sub.num_rects) {
+		rect := C.subtitle_rect(&sub, C.uint(i))
+		switch rect._type {
+		case C.SUBTITLE_TEXT:
+			lines = append(lines, C.GoString(rect.text))
+		case C.SUBTITLE_ASS:
+			lines = append(lines, asstext(C.GoString(rect.ass)))
+		}
+	}
+	if len(lines) == 0 || !sd.gate.pass(start, end) {
+		return nil
+	}
+
+	// cues only leave the buffer once they are over, which takes the
+	// clock moving. Blocking here would stall the demuxer and with it the
+	// audio driving the clock, a cue that doesn't fit is dropped instead.
+	// Expired ones are only ever taken out by the consumer.
+	sBuffer.TryPush(SubtitleData{
+		Start:  start,
+		End:    end,
+		Text:   strings.Join(lines, "\n"),
+		Serial: serial,
+	})
+
+	return nil
+}
+
+// asstext strips an ASS dialogue event down to its text. The event is
+// ReadOrder,Layer,Style,Name,MarginL,MarginR,MarginV,Effect,Text and the
+// text may hold {override} blocks and \N line breaks.
+func asstext(event string) string {
+	fields := strings.SplitN(event, ",", 9)
+	text := fields[len(fields)-1]
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range text {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+
+	text = b.String()
+	text = strings.ReplaceAll(text, `\N`, "\n")
+	text = strings.ReplaceAll(text, `\n`, "\n")
+	text = strings.ReplaceAll(text, `\h`, " ")
+	return text
+}
@@ -0,0 +1,180 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astiav"
+)
+
+// Track describes one stream of the loaded media
+type Track struct {
+	Index    int
+	Type     astiav.MediaType
+	Codec    string
+	Language string
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+func tracksof(ic *astiav.FormatContext) []Track {
+	var tracks []Track
+
+	for _, s := range ic.Streams() {
+		t := Track{
+			Index:   s.Index(),
+			Type:    s.CodecParameters().MediaType(),
+			Codec:   s.CodecParameters().CodecID().Name(),
+			Default: s.DispositionFlags().Has(astiav.DispositionFlagDefault),
+			Forced:  s.DispositionFlags().Has(astiav.DispositionFlagForced),
+		}
+
+		if md := s.Metadata(); md != nil {
+			if e := md.Get("language", nil, astiav.NewDictionaryFlags()); e != nil {
+				t.Language = e.Value()
+			}
+			if e := md.Get("title", nil, astiav.NewDictionaryFlags()); e != nil {
+				t.Title = e.Value()
+			}
+		}
+
+		tracks = append(tracks, t)
+	}
+
+	return tracks
+}
+
+// Tracks lists every stream of the loaded media
+func (c *Codec) Tracks() []Track {
+	return append([]Track(nil), c.tracks...)
+}
+
+// AudioTrack returns the index of the decoded audio stream, -1 if none
+func (c *Codec) AudioTrack() int {
+	return c.audioidx
+}
+
+// VideoTrack returns the index of the decoded video stream, -1 if none
+func (c *Codec) VideoTrack() int {
+	return c.videoidx
+}
+
+// SubtitleTrack returns the index of the decoded subtitle stream, -1 if
+// none
+func (c *Codec) SubtitleTrack() int {
+	return c.subtitleidx
+}
+
+func (c *Codec) stream(index int, mt astiav.MediaType) (*astiav.Stream, error) {
+	if c.ic == nil {
+		return nil, fmt.Errorf("codec: nothing loaded")
+	}
+
+	streams := c.ic.Streams()
+	if index < 0 || index >= len(streams) {
+		return nil, fmt.Errorf("codec: no stream %d", index)
+	}
+
+	s := streams[index]
+	if s.CodecParameters().MediaType() != mt {
+		return nil, fmt.Errorf("codec: stream %d is not %s", index, mt)
+	}
+
+	return s, nil
+}
+
+// SelectAudioTrack decodes the audio stream at index from now on. The
+// buffers are cleared, the caller seeks back to where it was.
+// Parse must not be running.
+func (c *Codec) SelectAudioTrack(index int) (*AudioMetadata, error) {
+	s, err := c.stream(index, astiav.MediaTypeAudio)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := newaudiodecoder()
+	am, err := c.loadaudio(ad, s)
+	if err != nil {
+		ad.close()
+		return nil, err
+	}
+
+	if c.aformat != (AudioFormat{}) {
+		if err := ad.setformat(c.aformat, c.downmix); err != nil {
+			ad.close()
+			return nil, err
+		}
+	}
+
+	c.audio.close()
+	c.audio = ad
+
+	c.discard()
+	c.AudioBuffer.Clear()
+	c.VideoBuffer.Clear()
+	c.SubtitleBuffer.Clear()
+
+	return am, nil
+}
+
+// SelectVideoTrack decodes the video stream at index from now on. The
+// buffers are cleared, the caller seeks back to where it was.
+// Parse must not be running.
+func (c *Codec) SelectVideoTrack(index int) (*VideoMetadata, error) {
+	s, err := c.stream(index, astiav.MediaTypeVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	vd := newvideodecoder(c.pool)
+	vm, err := c.loadvideo(vd, s)
+	if err != nil {
+		vd.close()
+		return nil, err
+	}
+
+	c.video.close()
+	c.video = vd
+	c.seekstream()
+
+	c.discard()
+	c.AudioBuffer.Clear()
+	c.VideoBuffer.Clear()
+	c.SubtitleBuffer.Clear()
+
+	return vm, nil
+}
+
+// SelectSubtitleTrack decodes the subtitle stream at index from now on,
+// -1 turns subtitles off. The buffers are cleared, the caller seeks back
+// to where it was. Parse must not be running.
+func (c *Codec) SelectSubtitleTrack(index int) error {
+	if index < 0 {
+		if c.subtitle != nil {
+			c.subtitle.close()
+		}
+		c.subtitle = newsubtitledecoder()
+		c.subtitleidx = -1
+	} else {
+		s, err := c.stream(index, astiav.MediaTypeSubtitle)
+		if err != nil {
+			return err
+		}
+
+		sd := newsubtitledecoder()
+		if err := c.loadsubtitle(sd, s); err != nil {
+			sd.close()
+			return err
+		}
+
+		c.subtitle.close()
+		c.subtitle = sd
+	}
+
+	c.discard()
+	c.AudioBuffer.Clear()
+	c.VideoBuffer.Clear()
+	c.SubtitleBuffer.Clear()
+
+	return nil
+}
@@ -13,16 +13,44 @@ type videodecoder struct {
 	closer *astikit.Closer
 
 	ctx *astiav.CodecContext
+	f   *astiav.Frame
+
+	// pictures are copied into buffers of the pool, the renderer gives
+	// them back once uploaded
+	pool *FramePool
+
+	// converts frames the renderer can't draw, created on demand
+	sws *astiav.SoftwareScaleContext
+	sf  *astiav.Frame
 
 	has      bool
+	cover    bool
 	timebase astiav.Rational
+
+	// static HDR metadata usually only comes with keyframes, the last
+	// one seen applies to the frames after it
+	mastering *MasteringDisplay
+	light     *ContentLight
+
+	gate seekgate
 }
 
-func newvideodecoder() *videodecoder {
-	vd := &videodecoder{}
+func newvideodecoder(pool *FramePool) *videodecoder {
+	vd := &videodecoder{pool: pool}
 
 	vd.closer = astikit.NewCloser()
 
+	vd.f = astiav.AllocFrame()
+	vd.closer.Add(vd.f.Free)
+
+	vd.sf = astiav.AllocFrame()
+	vd.closer.Add(vd.sf.Free)
+	vd.closer.Add(func() {
+		if vd.sws != nil {
+			vd.sws.Free()
+		}
+	})
+
 	return vd
 }
 
@@ -40,7 +68,7 @@ func (vd *videodecoder) load(stream *astiav.Stream) error {
 	if vd.ctx == nil {
 		return errors.New("video decoder: codec context is nil")
 	}
-	// vd.closer.Add(vd.ctx.Free)
+	vd.closer.Add(vd.ctx.Free)
 
 	err := stream.CodecParameters().ToCodecContext(vd.ctx)
 	if err != nil {
@@ -52,19 +80,19 @@ func (vd *videodecoder) load(stream *astiav.Stream) error {
 		return fmt.Errorf("video decoder: opening codec context failed: %w", err)
 	}
 
+	vd.cover = stream.DispositionFlags().Has(astiav.DispositionFlagAttachedPic)
 	vd.timebase = stream.TimeBase()
 	log.Printf("video timebase %v\n", vd.timebase)
 	vd.has = true
 	return nil
 }
 
-func (vd *videodecoder) decode(pkt *astiav.Packet, vBuffer *VideoBuffer) error {
+func (vd *videodecoder) decode(pkt *astiav.Packet, vBuffer *RingBuffer[VideoData], serial uint64) error {
 	if vd.ctx == nil {
 		return errors.New("decoder context is nil")
 	}
 
-	f := astiav.AllocFrame()
-	defer f.Free()
+	f := vd.f
 
 	err := vd.ctx.SendPacket(pkt)
 	if err != nil {
@@ -92,15 +120,71 @@ func (vd *videodecoder) decode(pkt *astiav.Packet, vBuffer *VideoBuffer) error {
 			defer f.Unref()
 
 			pts := float64(f.Pts()) * vd.timebase.Float64()
-			buf, _ := f.Data().Bytes(1)
-			vBuffer.Push(VideoData{
-				PTS:  pts,
-				W:    f.Width(),
-				H:    f.Height(),
-				Data: buf,
+			if !vd.gate.pass(pts, pts+vd.frameduration()) {
+				return false
+			}
+
+			src := f
+			if !renderable(f.PixelFormat()) {
+				if err := vd.convert(f); err != nil {
+					log.Println(fmt.Errorf("video decode: converting frame failed: %w", err))
+					return false
+				}
+				defer vd.sf.Unref()
+				src = vd.sf
+			}
+
+			size, err := src.ImageBufferSize(videoalign)
+			if err != nil {
+				log.Println(fmt.Errorf("video decode: sizing frame failed: %w", err))
+				return false
+			}
+
+			buf := vd.pool.get(size)
+			if _, err := src.ImageCopyToBuffer(buf, videoalign); err != nil {
+				vd.pool.put(buf)
+				log.Println(fmt.Errorf("video decode: copying frame failed: %w", err))
+				return false
+			}
+
+			planes, strides := splitplanes(src.PixelFormat(), src.Width(), src.Height(), buf, videoalign)
+
+			space, rng := vd.colorof(f)
+			if m := masteringof(f); m != nil {
+				vd.mastering = m
+			}
+			if l := contentlightof(f); l != nil {
+				vd.light = l
+			}
+
+			ok := vBuffer.Push(VideoData{
+				PTS:     pts,
+				W:       src.Width(),
+				H:       src.Height(),
+				Format:  src.PixelFormat(),
+				Planes:  planes,
+				Strides: strides,
+
+				ColorSpace:     space,
+				ColorRange:     rng,
+				ColorPrimaries: vd.ctx.ColorPrimaries(),
+				ColorTransfer:  vd.ctx.ColorTransferCharacteristic(),
+
+				Mastering:    vd.mastering,
+				ContentLight: vd.light,
+
+				Cover:  vd.cover,
+				Serial: serial,
+
+				buf:  buf,
+				pool: vd.pool,
 			})
 
-			return false
+			// buffer closed, nobody is going to read the rest
+			if !ok {
+				vd.pool.put(buf)
+			}
+			return !ok
 		}(); stop {
 			break
 		}
@@ -108,3 +192,76 @@ func (vd *videodecoder) decode(pkt *astiav.Packet, vBuffer *VideoBuffer) error {
 
 	return nil
 }
+
+// FrameDuration guesses how long a video frame lasts in seconds, 0
+// without video or frame rate
+func (c *Codec) FrameDuration() float64 {
+	if c.video == nil || c.videoidx < 0 {
+		return 0
+	}
+	return c.video.frameduration()
+}
+
+// frameduration guesses how long a frame lasts from the frame rate
+func (vd *videodecoder) frameduration() float64 {
+	if fr := vd.ctx.Framerate(); fr.Num() > 0 && fr.Den() > 0 {
+		return 1 / fr.Float64()
+	}
+	return 0
+}
+
+// convert scales f into vd.sf using the fallback pixel format
+func (vd *videodecoder) convert(f *astiav.Frame) error {
+	if vd.sws == nil ||
+		vd.sws.SourcePixelFormat() != f.PixelFormat() ||
+		vd.sws.SourceWidth() != f.Width() ||
+		vd.sws.SourceHeight() != f.Height() {
+		if vd.sws != nil {
+			vd.sws.Free()
+			vd.sws = nil
+		}
+
+		sws, err := astiav.CreateSoftwareScaleContext(
+			f.Width(), f.Height(), f.PixelFormat(),
+			f.Width(), f.Height(), fallbackformat,
+			astiav.NewSoftwareScaleContextFlags(astiav.SoftwareScaleContextFlagBilinear),
+		)
+		if err != nil {
+			return fmt.Errorf("video decoder: creating scale context failed: %w", err)
+		}
+		vd.sws = sws
+
+		log.Printf("video decoder: converting %s to %s\n", f.PixelFormat(), fallbackformat)
+	}
+
+	vd.sf.SetWidth(f.Width())
+	vd.sf.SetHeight(f.Height())
+	vd.sf.SetPixelFormat(fallbackformat)
+
+	if err := vd.sws.ScaleFrame(f, vd.sf); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// colorof returns the matrix and range of the picture pushed for f, once
+// converted when the renderer can't draw f as is
+func (vd *videodecoder) colorof(f *astiav.Frame) (astiav.ColorSpace, astiav.ColorRange) {
+	space, rng := f.ColorSpace(), f.ColorRange()
+	if space == astiav.ColorSpaceUnspecified {
+		space = vd.ctx.ColorSpace()
+	}
+	if rng == astiav.ColorRangeUnspecified {
+		rng = vd.ctx.ColorRange()
+	}
+
+	// swscale writes rgb sources as limited range BT.601
+	if !renderable(f.PixelFormat()) {
+		if d := f.PixelFormat().Descriptor(); d != nil && d.Flags().Has(astiav.PixelFormatDescriptorFlagRgb) {
+			return astiav.ColorSpaceBt470Bg, astiav.ColorRangeMpeg
+		}
+	}
+
+	return space, rng
+}
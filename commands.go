@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+
+	"GoldenFealla/go-video-player/input"
+)
+
+// volumestep is how much the volume command moves per unit, the volume
+// runs from 0 to 1 and the command takes percents
+const volumestep = 0.01
+
+// newcommands registers the commands the bindings can run, quit is set
+// by the quit command
+func newcommands(sess *session, br *browser, pp *playlistpanel, quit *bool) *input.Commands {
+	scr := sess.scr
+
+	cmds := input.NewCommands()
+
+	cmds.Register("pause", func(args []string) error {
+		p.TogglePause()
+		return nil
+	})
+
+	cmds.Register("seek", func(args []string) error {
+		d, err := input.Float(args, 0, 0)
+		if err != nil {
+			return err
+		}
+		target := max(float64(p.GetSecond())+d, 0)
+		if p.Duration > 0 {
+			target = min(target, float64(p.Duration))
+		}
+		p.SeekSecond(float32(target))
+		return nil
+	})
+
+	cmds.Register("volume", func(args []string) error {
+		d, err := input.Float(args, 0, 0)
+		if err != nil {
+			return err
+		}
+		p.SetVolume(p.Volume() + float32(d*volumestep))
+		return nil
+	})
+
+	cmds.Register("mute", func(args []string) error {
+		muted, err := input.Toggle(args, 0, p.Muted())
+		if err != nil {
+			return err
+		}
+		p.SetMuted(muted)
+		return nil
+	})
+
+	// either fullscreen is left by both commands
+	fullscreen := func(mode fullscreenmode) input.Handler {
+		return func(args []string) error {
+			on := scr.fullscreen() != windowed
+			want, err := input.Toggle(args, 0, on)
+			if err != nil || want == on {
+				return err
+			}
+			if !want {
+				return scr.setfullscreen(windowed)
+			}
+			return scr.setfullscreen(mode)
+		}
+	}
+	cmds.Register("fullscreen", fullscreen(fullscreenDesktop))
+	cmds.Register("fullscreen-exclusive", fullscreen(fullscreenExclusive))
+
+	cmds.Register("borderless", func(args []string) error {
+		on, err := input.Toggle(args, 0, scr.borderless())
+		if err != nil {
+			return err
+		}
+		scr.setborderless(on)
+		return nil
+	})
+
+	cmds.Register("ontop", func(args []string) error {
+		on, err := input.Toggle(args, 0, scr.ontop())
+		if err != nil {
+			return err
+		}
+		scr.setontop(on)
+		return nil
+	})
+
+	cmds.Register("window-scale", func(args []string) error {
+		f, err := input.Float(args, 0, 1)
+		if err != nil {
+			return err
+		}
+		if f <= 0 {
+			return fmt.Errorf("scale %g is not positive", f)
+		}
+		scr.setscale(f)
+		return nil
+	})
+
+	cmds.Register("frame-step", func(args []string) error {
+		p.StepFrame()
+		return nil
+	})
+
+	cmds.Register("frame-back-step", func(args []string) error {
+		p.StepBackFrame()
+		return nil
+	})
+
+	cmds.Register("speed", func(args []string) error {
+		f, err := input.Float(args, 0, 1)
+		if err != nil {
+			return err
+		}
+		if f <= 0 {
+			return fmt.Errorf("factor %g is not positive", f)
+		}
+		p.SetSpeed(p.Speed() * f)
+		return nil
+	})
+
+	cmds.Register("speed-reset", func(args []string) error {
+		p.SetSpeed(1)
+		return nil
+	})
+
+	cmds.Register("playlist-next", func(args []string) error {
+		return sess.next()
+	})
+
+	cmds.Register("playlist-prev", func(args []string) error {
+		return sess.prev()
+	})
+
+	cmds.Register("playlist-shuffle", func(args []string) error {
+		on, err := input.Toggle(args, 0, sess.list.Shuffle())
+		if err != nil {
+			return err
+		}
+		sess.list.SetShuffle(on)
+		return nil
+	})
+
+	// without argument it cycles through the modes
+	cmds.Register("playlist-repeat", func(args []string) error {
+		if len(args) == 0 {
+			i := slices.Index(repeatmodes, sess.list.Repeat())
+			sess.list.SetRepeat(repeatmodes[(i+1)%len(repeatmodes)])
+			return nil
+		}
+		for _, r := range repeatmodes {
+			if args[0] == r.String() {
+				sess.list.SetRepeat(r)
+				return nil
+			}
+		}
+		return fmt.Errorf("argument 1: %q is not off, one or all", args[0])
+	})
+
+	cmds.Register("playlist", func(args []string) error {
+		pp.toggle()
+		return nil
+	})
+
+	cmds.Register("browse", func(args []string) error {
+		br.toggle()
+		return nil
+	})
+
+	cmds.Register("quit", func(args []string) error {
+		*quit = true
+		return nil
+	})
+
+	return cmds
+}
+
+// loadbindings reads the defaults and the user config on top, path
+// empty means the default location where the file is optional
+func loadbindings(path string, cmds *input.Commands) input.Bindings {
+	b := input.DefaultBindings()
+
+	optional := path == ""
+	if optional {
+		path = input.DefaultPath()
+	}
+
+	if path != "" {
+		err := b.Load(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && optional:
+		case err != nil:
+			log.Println(fmt.Errorf("loading bindings failed: %w", err))
+		default:
+			log.Printf("loaded bindings from %s\n", path)
+		}
+	}
+
+	for _, err := range cmds.Check(b) {
+		log.Println(err)
+	}
+
+	return b
+}
+
+// runbinding runs the command bound to key, if any
+func runbinding(b input.Bindings, cmds *input.Commands, key string) {
+	line, ok := b.Lookup(key)
+	if !ok {
+		return
+	}
+	if err := cmds.Run(line); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,148 @@
+package input
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bindings maps key names, as KeyName, MouseName and WheelName return
+// them, to command lines
+type Bindings map[string]string
+
+// unbind is the command that removes a default binding
+const unbind = "ignore"
+
+// defaultconf is read before the user config, which overrides it key by
+// key. Most keys do what they do in mpv.
+const defaultconf = `
+SPACE         pause
+p             pause
+LEFT          seek -5
+RIGHT         seek 5
+Shift+LEFT    seek -60
+Shift+RIGHT   seek 60
+WHEEL_UP      seek 10
+WHEEL_DOWN    seek -10
+UP            volume 5
+DOWN          volume -5
+9             volume -5
+0             volume 5
+m             mute
+f             fullscreen
+F             fullscreen-exclusive
+MBTN_LEFT_DBL fullscreen
+ESC           fullscreen off
+Alt+b         borderless
+T             ontop
+Alt+0         window-scale 0.5
+Alt+1         window-scale 1
+Alt+2         window-scale 2
+.             frame-step
+,             frame-back-step
+[             speed 0.909091
+]             speed 1.1
+BS            speed-reset
+Ctrl+o        browse
+Shift+.       playlist-next
+Shift+,       playlist-prev
+ENTER         playlist-next
+L             playlist-repeat
+Alt+s         playlist-shuffle
+F8            playlist
+q             quit
+`
+
+// DefaultBindings returns the built-in bindings
+func DefaultBindings() Bindings {
+	b := Bindings{}
+	if err := b.Parse(strings.NewReader(defaultconf)); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// DefaultPath returns where the user config is looked for, empty when
+// the system has no config directory
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-video-player", "input.conf")
+}
+
+// Parse reads bindings, one "KEY command [args...]" per line, on top of
+// the ones in b. Lines starting with # are comments, binding a key to
+// "ignore" removes it.
+func (b Bindings) Parse(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, cmd := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			key, cmd = line[:i], line[i+1:]
+		}
+
+		// trailing comments
+		if i := strings.Index(cmd, " #"); i >= 0 {
+			cmd = cmd[:i]
+		}
+		cmd = strings.Join(strings.Fields(cmd), " ")
+		if cmd == "" {
+			return fmt.Errorf("input: line %d: no command for %s", n, key)
+		}
+
+		name, err := normalize(key)
+		if err != nil {
+			return fmt.Errorf("input: line %d: %w", n, err)
+		}
+
+		if cmd == unbind {
+			delete(b, name)
+			continue
+		}
+		b[name] = cmd
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("input: reading bindings failed: %w", err)
+	}
+	return nil
+}
+
+// Load reads the config file at path on top of b, a missing file is
+// reported as fs.ErrNotExist
+func (b Bindings) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return fmt.Errorf("input: opening %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := b.Parse(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Lookup returns the command bound to key
+func (b Bindings) Lookup(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	cmd, ok := b[key]
+	return cmd, ok
+}
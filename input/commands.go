@@ -0,0 +1,91 @@
+package input
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Handler runs a command with the arguments that followed its name
+type Handler func(args []string) error
+
+// Commands is the set of named commands bindings can run
+type Commands struct {
+	handlers map[string]Handler
+}
+
+func NewCommands() *Commands {
+	return &Commands{
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register adds the command name, replacing the one registered before
+func (c *Commands) Register(name string, h Handler) {
+	c.handlers[name] = h
+}
+
+// Has reports whether the command name is registered
+func (c *Commands) Has(name string) bool {
+	_, ok := c.handlers[name]
+	return ok
+}
+
+// Run parses a command line and runs it
+func (c *Commands) Run(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("input: empty command")
+	}
+
+	h, ok := c.handlers[fields[0]]
+	if !ok {
+		return fmt.Errorf("input: unknown command %q", fields[0])
+	}
+
+	if err := h(fields[1:]); err != nil {
+		return fmt.Errorf("input: %s: %w", fields[0], err)
+	}
+	return nil
+}
+
+// Check returns an error for every binding to an unknown command
+func (c *Commands) Check(b Bindings) []error {
+	var errs []error
+	for key, line := range b {
+		name, _, _ := strings.Cut(line, " ")
+		if !c.Has(name) {
+			errs = append(errs, fmt.Errorf("input: %s is bound to unknown command %q", key, name))
+		}
+	}
+	return errs
+}
+
+// Float returns args[i] as a number, def when it is missing
+func Float(args []string, i int, def float64) (float64, error) {
+	if i >= len(args) {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(args[i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("argument %d: %q is not a number", i+1, args[i])
+	}
+	return v, nil
+}
+
+// Toggle returns what a flag should become given an "on", "off" or
+// "toggle" argument at args[i], toggle being the default
+func Toggle(args []string, i int, current bool) (bool, error) {
+	if i >= len(args) {
+		return !current, nil
+	}
+	switch args[i] {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	case "toggle":
+		return !current, nil
+	}
+	return false, fmt.Errorf("argument %d: %q is not on, off or toggle", i+1, args[i])
+}
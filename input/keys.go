@@ -0,0 +1,193 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// special keys by the names the config file uses, they follow mpv
+var keynames = map[sdl.Keycode]string{
+	sdl.K_SPACE:     "SPACE",
+	sdl.K_RETURN:    "ENTER",
+	sdl.K_KP_ENTER:  "KP_ENTER",
+	sdl.K_ESCAPE:    "ESC",
+	sdl.K_BACKSPACE: "BS",
+	sdl.K_TAB:       "TAB",
+	sdl.K_DELETE:    "DEL",
+	sdl.K_INSERT:    "INS",
+	sdl.K_HOME:      "HOME",
+	sdl.K_END:       "END",
+	sdl.K_PAGEUP:    "PGUP",
+	sdl.K_PAGEDOWN:  "PGDWN",
+	sdl.K_LEFT:      "LEFT",
+	sdl.K_RIGHT:     "RIGHT",
+	sdl.K_UP:        "UP",
+	sdl.K_DOWN:      "DOWN",
+	sdl.K_F1:        "F1",
+	sdl.K_F2:        "F2",
+	sdl.K_F3:        "F3",
+	sdl.K_F4:        "F4",
+	sdl.K_F5:        "F5",
+	sdl.K_F6:        "F6",
+	sdl.K_F7:        "F7",
+	sdl.K_F8:        "F8",
+	sdl.K_F9:        "F9",
+	sdl.K_F10:       "F10",
+	sdl.K_F11:       "F11",
+	sdl.K_F12:       "F12",
+}
+
+// mouse actions have fixed names as there is no keycode for them
+const (
+	MouseLeftDouble = "MBTN_LEFT_DBL"
+	MouseRight      = "MBTN_RIGHT"
+	MouseMiddle     = "MBTN_MID"
+	WheelUp         = "WHEEL_UP"
+	WheelDown       = "WHEEL_DOWN"
+)
+
+// SDL_MOUSEWHEEL_FLIPPED, go-sdl2 has no constant for it
+const wheelflipped = 1
+
+var mousenames = map[string]bool{
+	MouseLeftDouble: true,
+	MouseRight:      true,
+	MouseMiddle:     true,
+	WheelUp:         true,
+	WheelDown:       true,
+}
+
+// modifiers in the order they are written in a key name
+var modifiers = []struct {
+	name string
+	mod  uint16
+}{
+	{"Ctrl", sdl.KMOD_CTRL},
+	{"Alt", sdl.KMOD_ALT},
+	{"Shift", sdl.KMOD_SHIFT},
+	{"Meta", sdl.KMOD_GUI},
+}
+
+// withmods prefixes name with the modifiers held in mod
+func withmods(name string, mod uint16) string {
+	var b strings.Builder
+	for _, m := range modifiers {
+		if mod&m.mod != 0 {
+			b.WriteString(m.name)
+			b.WriteByte('+')
+		}
+	}
+	b.WriteString(name)
+	return b.String()
+}
+
+// KeyName returns the name of a pressed key, empty for keys that can't
+// be bound. Printable keys are named by their unshifted character.
+func KeyName(k sdl.Keysym) string {
+	name, ok := keynames[k.Sym]
+	if !ok {
+		c := rune(k.Sym)
+		if c <= ' ' || c >= 0x7f {
+			return ""
+		}
+		name = string(c)
+	}
+	return withmods(name, k.Mod)
+}
+
+// MouseName returns the name of a mouse button press, empty for the ones
+// that can't be bound. The left button only counts double clicks, a
+// single one belongs to the UI.
+func MouseName(e *sdl.MouseButtonEvent) string {
+	if e.State != sdl.PRESSED {
+		return ""
+	}
+
+	mod := uint16(sdl.GetModState())
+	switch {
+	case e.Button == sdl.BUTTON_LEFT && e.Clicks == 2:
+		return withmods(MouseLeftDouble, mod)
+	case e.Button == sdl.BUTTON_RIGHT:
+		return withmods(MouseRight, mod)
+	case e.Button == sdl.BUTTON_MIDDLE:
+		return withmods(MouseMiddle, mod)
+	}
+	return ""
+}
+
+// WheelName returns the name of a wheel turn, empty when it is sideways
+func WheelName(e *sdl.MouseWheelEvent) string {
+	y := e.Y
+	if e.Direction == wheelflipped {
+		y = -y
+	}
+
+	mod := uint16(sdl.GetModState())
+	switch {
+	case y > 0:
+		return withmods(WheelUp, mod)
+	case y < 0:
+		return withmods(WheelDown, mod)
+	}
+	return ""
+}
+
+// normalize turns a key name from the config file into the form KeyName
+// returns, modifiers in any order and case and a capital letter standing
+// for Shift and the letter
+func normalize(key string) (string, error) {
+	parts := strings.Split(key, "+")
+
+	// "+" alone or as the last key, e.g. "Ctrl++"
+	if strings.HasSuffix(key, "++") || key == "+" {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+
+	name := parts[len(parts)-1]
+	if name == "" {
+		return "", fmt.Errorf("empty key in %q", key)
+	}
+
+	var mod uint16
+	for _, p := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range modifiers {
+			if strings.EqualFold(p, m.name) {
+				mod |= m.mod
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("unknown modifier %q in %q", p, key)
+		}
+	}
+
+	switch {
+	case len(name) == 1 && name[0] >= 'A' && name[0] <= 'Z':
+		mod |= sdl.KMOD_SHIFT
+		name = strings.ToLower(name)
+	case len(name) == 1 && name[0] > ' ' && name[0] < 0x7f:
+	default:
+		upper := strings.ToUpper(name)
+		if !knownname(upper) {
+			return "", fmt.Errorf("unknown key %q", key)
+		}
+		name = upper
+	}
+
+	return withmods(name, mod), nil
+}
+
+func knownname(name string) bool {
+	if mousenames[name] {
+		return true
+	}
+	for _, n := range keynames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
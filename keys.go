@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// imguikeys are the keys text fields need, the characters come through
+// TextInputEvent
+var imguikeys = map[sdl.Keycode]imgui.Key{
+	sdl.K_TAB:       imgui.KeyTab,
+	sdl.K_LEFT:      imgui.KeyLeftArrow,
+	sdl.K_RIGHT:     imgui.KeyRightArrow,
+	sdl.K_HOME:      imgui.KeyHome,
+	sdl.K_END:       imgui.KeyEnd,
+	sdl.K_DELETE:    imgui.KeyDelete,
+	sdl.K_BACKSPACE: imgui.KeyBackspace,
+	sdl.K_RETURN:    imgui.KeyEnter,
+	sdl.K_KP_ENTER:  imgui.KeyKeypadEnter,
+	sdl.K_ESCAPE:    imgui.KeyEscape,
+	sdl.K_a:         imgui.KeyA,
+	sdl.K_c:         imgui.KeyC,
+	sdl.K_v:         imgui.KeyV,
+	sdl.K_x:         imgui.KeyX,
+	sdl.K_y:         imgui.KeyY,
+	sdl.K_z:         imgui.KeyZ,
+}
+
+// forwardkey passes a key press or release on to imgui
+func forwardkey(io *imgui.IO, e *sdl.KeyboardEvent) {
+	down := e.Type == sdl.KEYDOWN
+
+	io.AddKeyEvent(imgui.ModCtrl, e.Keysym.Mod&sdl.KMOD_CTRL != 0)
+	io.AddKeyEvent(imgui.ModShift, e.Keysym.Mod&sdl.KMOD_SHIFT != 0)
+
+	if key, ok := imguikeys[e.Keysym.Sym]; ok {
+		io.AddKeyEvent(key, down)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -9,7 +10,9 @@ import (
 	"strings"
 
 	"GoldenFealla/go-video-player/codec"
+	"GoldenFealla/go-video-player/input"
 	"GoldenFealla/go-video-player/player"
+	"GoldenFealla/go-video-player/playlist"
 	"GoldenFealla/go-video-player/shader"
 
 	"github.com/AllenDang/cimgui-go/imgui"
@@ -53,20 +56,62 @@ func init() {
 
 func main() {
 	defer sdl.Quit()
+
+	o, err := parseargs(os.Args[1:], os.Stderr)
+	switch {
+	case errors.Is(err, errusage):
+		os.Exit(2)
+	case err != nil:
+		fail(err)
+	case o.help:
+		return
+	case o.version:
+		fmt.Println("go-video-player", version)
+		return
+	}
+
+	opts := []player.Option{}
+	if o.novideo {
+		opts = append(opts, player.WithoutVideo())
+	}
+	if o.noaudio {
+		opts = append(opts, player.WithoutAudio())
+	}
+
+	p = player.NewPlayer(opts...)
+	defer p.Close()
+
+	p.SetVolume(float32(o.volume) / 100)
+	p.SetMuted(o.mute)
+	p.SetSpeed(o.speed)
+	p.SetLoop(o.loop)
+
+	list := playlist.New()
+	list.Add(entries(o.files)...)
+	list.SetShuffle(o.shuffle)
+	if o.loopplaylist {
+		list.SetRepeat(playlist.RepeatAll)
+	}
+
+	// nothing to show when not a single file opens
+	sess := &session{o: o, recent: loadrecent(), list: list}
+	if err := sess.load(list.Next); err != nil {
+		p.Close()
+		sdl.Quit()
+		fail(err)
+	}
+
 	// ==== AUDIO =====
 	log.Printf("Use audio driver: %v\n", sdl.GetCurrentAudioDriver())
 
 	// ====== GUI ======
-	window, err := sdl.CreateWindow(
-		"Player",
-		sdl.WINDOWPOS_CENTERED,
-		sdl.WINDOWPOS_CENTERED,
-		1280, 720,
-		sdl.WINDOW_OPENGL|sdl.WINDOW_RESIZABLE,
-	)
+	videoW, videoH := p.VideoSize()
+	scr, err := newscreen(o, videoW, videoH)
 	if err != nil {
 		panic(err)
 	}
+	defer scr.close()
+	window := scr.win
 
 	glContext, err := window.GLCreateContext()
 	if err != nil {
@@ -101,18 +146,29 @@ func main() {
 	opengl3.CreateDeviceObjects()
 	defer opengl3.DestroyDeviceObjects()
 
-	p = player.NewPlayer()
-	p.Load("test_video_3.mp4")
+	// frames are decoded into the mapped upload buffers when there are
+	if a := shader.FrameAllocator(); a != nil {
+		p.SetFrameAllocator(a)
+	}
+
+	sess.scr = scr
+	sess.started()
+
+	br := newbrowser(sess.recent)
+	pp := newplaylistpanel()
 
-	go p.Play()
+	quit := false
+	cmds := newcommands(sess, br, pp, &quit)
+	bindings := loadbindings(o.inputconf, cmds)
 
-	var latestFrame codec.VideoData
+	// files dropped together are opened as one list
+	var dropped []string
 
 	var sliderSecond float32
 	var sliderSecondV float32
 
 	// ====== LOOP =====
-	for {
+	for !quit {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 			switch e := event.(type) {
 
@@ -121,9 +177,42 @@ func main() {
 
 			case *sdl.MouseButtonEvent:
 				io.AddMouseButtonEvent(int32(e.Button-1), e.State == sdl.PRESSED)
+				// clicks on the controls are theirs
+				if !io.WantCaptureMouse() {
+					runbinding(bindings, cmds, input.MouseName(e))
+				}
 
 			case *sdl.MouseWheelEvent:
 				io.AddMouseWheelEvent(float32(e.X), float32(e.Y))
+				if !io.WantCaptureMouse() {
+					runbinding(bindings, cmds, input.WheelName(e))
+				}
+
+			case *sdl.WindowEvent:
+				if e.Event == sdl.WINDOWEVENT_MOVED {
+					scr.moved()
+				}
+
+			case *sdl.KeyboardEvent:
+				forwardkey(io, e)
+				if e.Type == sdl.KEYDOWN && !io.WantTextInput() {
+					runbinding(bindings, cmds, input.KeyName(e.Keysym))
+				}
+
+			case *sdl.DropEvent:
+				switch e.Type {
+				case sdl.DROPBEGIN:
+					dropped = nil
+				case sdl.DROPFILE:
+					dropped = append(dropped, e.File)
+				case sdl.DROPCOMPLETE:
+					if len(dropped) > 0 {
+						if err := sess.open(dropped); err != nil {
+							log.Println(err)
+						}
+					}
+					dropped = nil
+				}
 
 			case *sdl.TextInputEvent:
 				io.AddInputCharactersUTF8(string(e.Text[:]))
@@ -133,9 +222,11 @@ func main() {
 			}
 		}
 
-		f := p.LatestFrame()
-		if len(f.Data) > 0 {
-			latestFrame = f
+		sess.advance()
+
+		// upload as soon as possible, the planes go back to the pool
+		if f := p.LatestFrame(); len(f.Planes) > 0 {
+			shader.Upload(f)
 		}
 
 		w, h := window.GetSize()
@@ -166,6 +257,14 @@ func main() {
 			imgui.WindowFlagsNoCollapse
 
 		imgui.BeginV("Control", nil, flags)
+		if imgui.Button("Open") {
+			br.toggle()
+		}
+		imgui.SameLine()
+		if imgui.Button("Playlist") {
+			pp.toggle()
+		}
+		imgui.SameLine()
 		avail := imgui.ContentRegionAvail().X
 
 		imgui.PushItemWidth(avail * 0.7)
@@ -175,6 +274,8 @@ func main() {
 		}
 		if imgui.SliderFloatV("##second", &sliderSecond, 0, p.Duration, "", imgui.SliderFlagsAlwaysClamp) {
 			sliderSecondV = sliderSecond
+			// keyframes only while dragging, the precise seek comes on release
+			p.Seek(sliderSecond, codec.SeekKeyframe)
 		}
 		if imgui.IsItemDeactivatedAfterEdit() {
 			p.SeekSecond(sliderSecondV)
@@ -185,7 +286,10 @@ func main() {
 		imgui.SameLine()
 
 		imgui.PushItemWidth(avail * 0.2)
-		imgui.SliderFloat("##volume", &p.Volume, 0, 1)
+		volume := p.Volume()
+		if imgui.SliderFloat("##volume", &volume, 0, 1) {
+			p.SetVolume(volume)
+		}
 		imgui.PopItemWidth()
 
 		imgui.SameLine()
@@ -195,21 +299,76 @@ func main() {
 		imgui.PopItemWidth()
 
 		imgui.End()
+
+		if !p.HasVideo() {
+			drawPlaceholder(float32(w), float32(h)-barHeight)
+		}
+		if text := p.Subtitle(); text != "" {
+			drawSubtitle(text, float32(w), float32(h)-barHeight)
+		}
+		pp.draw(sess, float32(w), float32(h)-barHeight)
+		if path, ok := br.draw(float32(w), float32(h)-barHeight); ok {
+			if err := sess.open([]string{path}); err != nil {
+				log.Println(err)
+				br.err = err.Error()
+			} else {
+				br.open = false
+			}
+		}
+
 		imgui.Render()
 
 		// --- render ---
 		gl.Viewport(0, 0, int32(w), int32(h))
 		gl.Clear(gl.COLOR_BUFFER_BIT)
 
-		if len(latestFrame.Data) > 0 {
-			shader.RenderYUV(latestFrame, int(w), int(h))
-		}
+		shader.Draw(int(w), int(h))
 
 		opengl3.RenderDrawData(imgui.CurrentDrawData())
 		window.GLSwap()
 	}
 }
 
+// drawPlaceholder fills the video area of media without any picture
+func drawPlaceholder(w, h float32) {
+	text := "Audio only"
+	size := imgui.CalcTextSize(text)
+
+	imgui.SetNextWindowPos(imgui.Vec2{
+		X: (w - size.X) / 2,
+		Y: (h - size.Y) / 2,
+	})
+
+	flags := imgui.WindowFlagsNoTitleBar |
+		imgui.WindowFlagsNoBackground |
+		imgui.WindowFlagsAlwaysAutoResize |
+		imgui.WindowFlagsNoInputs
+
+	imgui.BeginV("Placeholder", nil, flags)
+	imgui.Text(text)
+	imgui.End()
+}
+
+// drawSubtitle shows text centered at the bottom of the video area
+func drawSubtitle(text string, w, h float32) {
+	size := imgui.CalcTextSize(text)
+
+	imgui.SetNextWindowPos(imgui.Vec2{
+		X: (w - size.X) / 2,
+		Y: h - size.Y - 40,
+	})
+
+	flags := imgui.WindowFlagsNoTitleBar |
+		imgui.WindowFlagsAlwaysAutoResize |
+		imgui.WindowFlagsNoInputs
+
+	imgui.SetNextWindowBgAlpha(0.5)
+	imgui.BeginV("Subtitle", nil, flags)
+	// subtitles often hold %, Text would read it as a format
+	imgui.TextUnformatted(text)
+	imgui.End()
+}
+
 func formatDuration(sec float32) string {
 	totalSeconds := int(math.Round(float64(sec)))
 
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"GoldenFealla/go-video-player/player"
+	"GoldenFealla/go-video-player/playlist"
+	"GoldenFealla/go-video-player/shader"
+)
+
+// session ties the playlist to the player and the window
+type session struct {
+	o      options
+	scr    *screen
+	recent *recentfiles
+	list   *playlist.Playlist
+
+	// the end of the playlist was reached, nothing more to advance to
+	done bool
+}
+
+// entries turns paths into playlist entries, playlist files are
+// expanded into what they list. HLS playlists and URLs are media.
+func entries(paths []string) []playlist.Entry {
+	var es []playlist.Entry
+	for _, path := range paths {
+		if !playlist.IsPlaylist(path) {
+			es = append(es, playlist.NewEntry(path))
+			continue
+		}
+
+		listed, err := playlist.Load(path)
+		if errors.Is(err, playlist.ErrHLS) {
+			es = append(es, playlist.NewEntry(path))
+			continue
+		}
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		es = append(es, listed...)
+	}
+	return es
+}
+
+// load moves through the playlist with step until an entry loads, every
+// entry is tried at most once. The playback is left to the caller.
+func (s *session) load(step func() (playlist.Entry, bool)) error {
+	var err error
+	for range s.list.Len() {
+		e, ok := step()
+		if !ok {
+			break
+		}
+
+		if err = loadmedia(p, s.o, e.Path); err == nil {
+			if rerr := s.recent.add(e.Path); rerr != nil {
+				log.Println(rerr)
+			}
+			return nil
+		}
+		log.Println(err)
+	}
+
+	if err == nil {
+		err = errors.New("nothing to play")
+	}
+	return err
+}
+
+// started sets up the window for the media just loaded and plays it
+func (s *session) started() {
+	shader.Invalidate()
+	if s.scr != nil {
+		s.scr.fit(p.VideoSize())
+	}
+	p.Play()
+}
+
+// play loads with step and starts the playback. Loading tears down what
+// played, it is loaded again where it was when nothing else loads.
+func (s *session) play(step func() (playlist.Entry, bool)) error {
+	path, pos, state := p.Path(), p.GetSecond(), p.State()
+	current := s.list.Current()
+
+	if err := s.load(step); err != nil {
+		s.restore(path, pos, state, current)
+		return err
+	}
+	s.started()
+	return nil
+}
+
+// restore loads path again at pos, playing if it was, and makes current
+// the playlist entry again
+func (s *session) restore(path string, pos float32, state player.State, current int) {
+	if path == "" {
+		return
+	}
+
+	if current >= 0 {
+		s.list.Jump(current)
+	}
+
+	if err := loadmedia(p, s.o, path); err != nil {
+		log.Println(err)
+		return
+	}
+	p.SeekSecond(pos)
+	if state == player.StatePlaying {
+		p.Play()
+	}
+}
+
+// open adds files to the playlist and plays the first of them that loads
+func (s *session) open(files []string) error {
+	first := s.list.Len()
+	s.list.Add(entries(files)...)
+	if s.list.Len() == first {
+		return fmt.Errorf("nothing to play in %v", files)
+	}
+
+	i := first
+	return s.play(func() (playlist.Entry, bool) {
+		if i >= s.list.Len() {
+			return playlist.Entry{}, false
+		}
+		i++
+		return s.list.Jump(i - 1)
+	})
+}
+
+func (s *session) next() error {
+	return s.play(s.list.Next)
+}
+
+func (s *session) prev() error {
+	return s.play(s.list.Prev)
+}
+
+// jump plays the entry at index i, then the ones after it if it doesn't
+// load
+func (s *session) jump(i int) error {
+	first := true
+	return s.play(func() (playlist.Entry, bool) {
+		if first {
+			first = false
+			return s.list.Jump(i)
+		}
+		return s.list.Next()
+	})
+}
+
+// advance moves on once the media ended, the loops of a single file are
+// done by the player
+func (s *session) advance() {
+	if p.State() != player.StateEnded {
+		s.done = false
+		return
+	}
+	if s.done {
+		return
+	}
+
+	first := true
+	err := s.play(func() (playlist.Entry, bool) {
+		// the ones after a repeated entry that fails to load
+		if first {
+			first = false
+			return s.list.Advance()
+		}
+		return s.list.Next()
+	})
+	if err != nil {
+		// stay ended until something else is picked
+		s.done = true
+	}
+}
@@ -2,17 +2,197 @@ package player
 
 import (
 	"math"
-	"sync/atomic"
+	"sync"
+	"time"
 )
 
+// nosync is the drift in seconds past which a clock is snapped to the
+// other instead of being corrected, like AV_NOSYNC_THRESHOLD in ffplay
+const nosync = 10.0
+
+// clock runs from the last pts it was set to at the playback speed,
+// pausing it freezes the time
 type clock struct {
-	t atomic.Uint64
+	mu      sync.Mutex
+	pts     float64
+	updated time.Time
+	paused  bool
+	speed   float64
+}
+
+func newclock() *clock {
+	return &clock{updated: time.Now(), speed: 1}
 }
 
-func (c *clock) set(f float64) {
-	c.t.Store(math.Float64bits(f))
+func (c *clock) set(pts float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pts = pts
+	c.updated = time.Now()
 }
 
 func (c *clock) get() float64 {
-	return math.Float64frombits(c.t.Load())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+// now needs c.mu held
+func (c *clock) now() float64 {
+	if c.paused {
+		return c.pts
+	}
+	return c.pts + time.Since(c.updated).Seconds()*c.speed
+}
+
+func (c *clock) pause(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused == paused {
+		return
+	}
+	c.pts = c.now()
+	c.updated = time.Now()
+	c.paused = paused
+}
+
+func (c *clock) setspeed(speed float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pts = c.now()
+	c.updated = time.Now()
+	c.speed = speed
+}
+
+// follow snaps c to other when they drifted too far apart to be corrected
+func (c *clock) follow(other *clock) {
+	t := other.get()
+	if math.Abs(c.get()-t) > nosync {
+		c.set(t)
+	}
+}
+
+// MasterClock is the clock the other streams are synced to
+type MasterClock int
+
+const (
+	// MasterAudio paces video by the audio, the default
+	MasterAudio MasterClock = iota
+	// MasterVideo plays frames at their own pace and stretches the audio
+	// to follow
+	MasterVideo
+	// MasterExternal runs on the system clock, both audio and video
+	// follow it
+	MasterExternal
+)
+
+func (m MasterClock) String() string {
+	switch m {
+	case MasterAudio:
+		return "audio"
+	case MasterVideo:
+		return "video"
+	case MasterExternal:
+		return "external"
+	}
+	return "unknown"
+}
+
+// SetMasterClock picks the clock to sync to, it takes effect right away
+func (p *Player) SetMasterClock(m MasterClock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.master = m
+	p.async.reset()
+}
+
+// MasterClock returns the clock actually in use, it falls back from the
+// selected one when the media lacks that stream
+func (p *Player) MasterClock() MasterClock {
+	p.mu.Lock()
+	m := p.master
+	p.mu.Unlock()
+
+	// a cover has no timing of its own
+	if m == MasterVideo && (!p.codec.HasVideo() || p.codec.HasCover()) {
+		m = MasterAudio
+	}
+	if m == MasterAudio && !p.codec.HasAudio() {
+		m = MasterExternal
+	}
+	return m
+}
+
+func (p *Player) masterclock() *clock {
+	switch p.MasterClock() {
+	case MasterVideo:
+		return p.vidclk
+	case MasterExternal:
+		return p.extclk
+	}
+	return p.audclk
+}
+
+// setclocks moves every clock to pts, used on load, stop and seek
+func (p *Player) setclocks(pts float64) {
+	p.avoffset.Store(0)
+	p.shown.Store(math.Float64bits(pts))
+	p.audclk.set(pts)
+	p.vidclk.set(pts)
+	p.extclk.set(pts)
+	p.async.reset()
+}
+
+func (p *Player) pauseclocks(paused bool) {
+	p.audclk.pause(paused)
+	p.vidclk.pause(paused)
+	p.extclk.pause(paused)
+}
+
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 4.0
+)
+
+// SetSpeed changes the playback speed, clamped to MinSpeed..MaxSpeed.
+// The audio is resampled to keep up, so its pitch follows the speed.
+func (p *Player) SetSpeed(speed float64) {
+	if math.IsNaN(speed) {
+		return
+	}
+	speed = min(max(speed, MinSpeed), MaxSpeed)
+
+	p.speed.Store(math.Float64bits(speed))
+	p.audclk.setspeed(speed)
+	p.vidclk.setspeed(speed)
+	p.extclk.setspeed(speed)
+	p.async.reset()
+}
+
+// Speed returns the playback speed, 1 is normal speed
+func (p *Player) Speed() float64 {
+	return math.Float64frombits(p.speed.Load())
+}
+
+// SetVolume sets the output volume, clamped to 0..1
+func (p *Player) SetVolume(volume float32) {
+	if math.IsNaN(float64(volume)) {
+		return
+	}
+	p.volume.Store(math.Float32bits(min(max(volume, 0), 1)))
+}
+
+// Volume returns the output volume, it is kept while muted
+func (p *Player) Volume() float32 {
+	return math.Float32frombits(p.volume.Load())
+}
+
+// SetMuted silences the output without touching the volume
+func (p *Player) SetMuted(muted bool) {
+	p.muted.Store(muted)
+}
+
+// Muted reports whether the output is silenced
+func (p *Player) Muted() bool {
+	return p.muted.Load()
 }
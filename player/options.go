@@ -0,0 +1,38 @@
+package player
+
+import "GoldenFealla/go-video-player/codec"
+
+// Option configures a Player, see NewPlayer
+type Option func(*Player)
+
+// WithChannels opens the audio device with n channels instead of the
+// source channel count, sources with more channels are downmixed
+func WithChannels(n int) Option {
+	return func(p *Player) {
+		p.channels = n
+	}
+}
+
+// WithoutVideo leaves the video out of the media loaded, it plays as
+// audio only
+func WithoutVideo() Option {
+	return func(p *Player) {
+		p.novideo = true
+	}
+}
+
+// WithoutAudio leaves the audio out of the media loaded, it plays on the
+// system clock
+func WithoutAudio() Option {
+	return func(p *Player) {
+		p.noaudio = true
+	}
+}
+
+// WithDownmix sets how sources with more channels than the audio device
+// are folded down
+func WithDownmix(d codec.Downmix) Option {
+	return func(p *Player) {
+		p.downmix = d
+	}
+}
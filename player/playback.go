@@ -1,10 +1,14 @@
 package player
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"time"
 
+	"GoldenFealla/go-video-player/codec"
+
+	"github.com/asticode/go-astiav"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
@@ -13,6 +17,7 @@ type playback struct {
 	target   int
 	deviceid sdl.AudioDeviceID
 	maxqueue uint32
+	format   codec.AudioFormat
 }
 
 func newplayback(target int) *playback {
@@ -21,33 +26,135 @@ func newplayback(target int) *playback {
 	}
 }
 
-func (pb *playback) load(freq int) {
-	var err error
+// load opens the audio device for am with the given channel count and
+// returns the format SDL actually gave us, the decoded audio has to be
+// resampled to it. The device may give fewer channels than asked for.
+func (pb *playback) load(am *codec.AudioMetadata, channels int) (codec.AudioFormat, error) {
+	desired := sdl.AudioSpec{
+		Channels: uint8(channels),
+		Freq:     int32(am.Freq),
+		Format:   sdl.AUDIO_F32SYS,
+	}
 
-	spec := sdl.AudioSpec{
-		Channels: uint8(2),
-		Freq:     int32(freq),
-		Format:   sdl.AUDIO_S16,
+	allowed := sdl.AUDIO_ALLOW_FREQUENCY_CHANGE | sdl.AUDIO_ALLOW_FORMAT_CHANGE | sdl.AUDIO_ALLOW_CHANNELS_CHANGE
+	format, err := pb.open(&desired, allowed)
+	if err != nil {
+		return codec.AudioFormat{}, err
 	}
 
-	pb.deviceid, err = sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if !codec.SupportedAudioFormat(format) {
+		// let SDL convert to what we asked for instead
+		log.Printf("audio device format %+v not supported, reopening\n", format)
+		pb.close()
+
+		format, err = pb.open(&desired, sdl.AUDIO_ALLOW_FREQUENCY_CHANGE)
+		if err != nil {
+			return codec.AudioFormat{}, err
+		}
+	}
+
+	pb.format = format
+	pb.maxqueue = uint32(format.BytesPerSecond() * pb.target / 1000)
+
+	log.Printf("opened audio device id: %v, format %+v\n", pb.deviceid, format)
+	return format, nil
+}
+
+func (pb *playback) open(desired *sdl.AudioSpec, allowed int) (codec.AudioFormat, error) {
+	var obtained sdl.AudioSpec
+
+	id, err := sdl.OpenAudioDevice("", false, desired, &obtained, allowed)
 	if err != nil {
-		panic(err)
+		return codec.AudioFormat{}, fmt.Errorf("playback: opening audio device failed: %w", err)
 	}
+	pb.deviceid = id
 
-	pb.maxqueue = uint32(2 * 4 * freq * pb.target / 1000)
+	format := codec.AudioFormat{
+		SampleRate: int(obtained.Freq),
+		Channels:   int(obtained.Channels),
+	}
+
+	switch obtained.Format {
+	case sdl.AUDIO_S16SYS:
+		format.SampleFormat = astiav.SampleFormatS16
+	case sdl.AUDIO_F32SYS:
+		format.SampleFormat = astiav.SampleFormatFlt
+	default:
+		format.SampleFormat = astiav.SampleFormatNone
+	}
 
-	log.Printf("opened audio device id: %v\n", pb.deviceid)
-	sdl.PauseAudioDevice(pb.deviceid, false)
+	return format, nil
 }
 
-func (pb *playback) play(samples []byte, volume float32) {
+// opened reports whether a device is open, there is none for silent media
+func (pb *playback) opened() bool {
+	return pb.deviceid != 0
+}
+
+func (pb *playback) pause(paused bool) {
+	if pb.deviceid == 0 {
+		return
+	}
+	sdl.PauseAudioDevice(pb.deviceid, paused)
+}
+
+// play queues samples once the device is below maxqueue, it reports
+// false when quit was closed while waiting
+func (pb *playback) play(samples []byte, volume float32, quit <-chan struct{}) bool {
 	for sdl.GetQueuedAudioSize(pb.deviceid) > pb.maxqueue {
-		time.Sleep(time.Millisecond)
+		select {
+		case <-quit:
+			return false
+		default:
+			time.Sleep(time.Millisecond)
+		}
 	}
 
-	applyVolume4(samples, volume)
+	if len(samples) == 0 {
+		return true
+	}
+
+	switch pb.format.SampleFormat {
+	case astiav.SampleFormatS16:
+		applyVolume4(samples, volume)
+	case astiav.SampleFormatFlt:
+		applyVolume8(samples, volume)
+	}
 	sdl.QueueAudio(pb.deviceid, samples)
+	return true
+}
+
+// duration returns how long n bytes of device audio play, in seconds
+func (pb *playback) duration(n int) float64 {
+	bps := pb.format.BytesPerSecond()
+	if bps <= 0 {
+		return 0
+	}
+	return float64(n) / float64(bps)
+}
+
+// queued returns how long the audio SDL still holds plays, in seconds
+func (pb *playback) queued() float64 {
+	if pb.deviceid == 0 {
+		return 0
+	}
+	return pb.duration(int(sdl.GetQueuedAudioSize(pb.deviceid)))
+}
+
+func (pb *playback) clear() {
+	if pb.deviceid == 0 {
+		return
+	}
+	sdl.ClearQueuedAudio(pb.deviceid)
+}
+
+func (pb *playback) close() {
+	if pb.deviceid == 0 {
+		return
+	}
+	sdl.CloseAudioDevice(pb.deviceid)
+	log.Printf("closed audio device id: %v\n", pb.deviceid)
+	pb.deviceid = 0
 }
 
 func applyVolume4(out []byte, vol float32) []byte {
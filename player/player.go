@@ -1,110 +1,675 @@
 package player
 
 import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"GoldenFealla/go-video-player/codec"
 
 	"github.com/asticode/go-astiav"
 )
 
+// viddrift is how far in seconds the video clock may drift off the
+// frames on screen before it is corrected
+const viddrift = 0.1
+
 type Player struct {
-	clock *clock
 	codec *codec.Codec
 	pb    *playback
 
-	Volume   float32
+	// the master is picked from these, see MasterClock
+	audclk *clock
+	vidclk *clock
+	extclk *clock
+	master MasterClock
+	async  audiosync
+
+	// audio clock minus the pts of the last frame shown, float64 bits
+	avoffset atomic.Uint64
+	// where the last seek landed, float64 bits
+	landed atomic.Uint64
+	// pts of the last frame shown, float64 bits
+	shown atomic.Uint64
+	// a frame step is waiting for its frame, see StepFrame
+	stepping atomic.Bool
+	// a step asks Clock to drop the audio before skip, float64 bits
+	skip     atomic.Uint64
+	skipping atomic.Bool
+	// playback speed, float64 bits
+	speed atomic.Uint64
+	// output volume from 0 to 1, float32 bits
+	volume atomic.Uint32
+	muted  atomic.Bool
+
+	mu      sync.Mutex
+	state   State
+	running bool
+	path    string
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	// audio output options, applied on Load
+	channels int
+	downmix  codec.Downmix
+
+	// streams left out, applied on Load
+	novideo bool
+	noaudio bool
+
+	// extra runs of the media, loops is what is left of loop for the
+	// current one, -1 repeats forever
+	loop  int
+	loops int
+
+	// size of the loaded video, 0 without video
+	videow int
+	videoh int
+
 	Duration float32
 }
 
-func NewPlayer() *Player {
-	return &Player{
+func NewPlayer(opts ...Option) *Player {
+	p := &Player{
 		codec:  codec.NewCodec(),
-		clock:  &clock{},
+		audclk: newclock(),
+		vidclk: newclock(),
+		extclk: newclock(),
 		pb:     newplayback(20),
-		Volume: 0.5,
+		state:  StateIdle,
 	}
+
+	p.speed.Store(math.Float64bits(1))
+	p.volume.Store(math.Float32bits(0.5))
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// SetLoop plays the media n more times once it ends, -1 repeats it
+// forever and 0 plays it once. It also applies to the media loaded next.
+func (p *Player) SetLoop(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loop = max(n, -1)
+	p.loops = p.loop
+}
+
+// Loop returns what SetLoop was given
+func (p *Player) Loop() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loop
+}
+
+// SetDownmix changes the downmix used from the next Load on
+func (p *Player) SetDownmix(d codec.Downmix) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.downmix = d
+}
+
+func (p *Player) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *Player) setState(s State) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = s
 }
 
+// Load opens path, replacing the current media if there is one. A player
+// that was playing keeps playing with the new file.
 func (p *Player) Load(path string) error {
-	_, am, err := p.codec.Load(path)
+	autoplay := p.State() == StatePlaying
+
+	p.Stop()
+
+	p.mu.Lock()
+	p.state = StateLoading
+	p.path = ""
+	p.loops = p.loop
+	p.codec.SetStreams(!p.novideo, !p.noaudio)
+	p.mu.Unlock()
+
+	p.pb.close()
+	p.setclocks(0)
+	p.Duration = 0
+
+	vm, am, err := p.codec.Load(path)
+
+	p.mu.Lock()
+	p.videow, p.videoh = 0, 0
+	if vm != nil {
+		p.videow, p.videoh = vm.W, vm.H
+	}
+	p.mu.Unlock()
+
 	if err != nil {
+		p.setState(StateError)
 		return err
 	}
 
-	p.pb.load(am.Freq)
+	// silent media runs on the wall clock instead
+	if am != nil {
+		if err := p.loadaudio(am); err != nil {
+			p.setState(StateError)
+			return err
+		}
+	}
+
 	p.Duration = float32(p.codec.Duration()) / float32(astiav.TimeBase)
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.path = path
+
+	// loaded but not started yet, Play or Resume starts the playback
+	p.state = StatePaused
+	if autoplay {
+		p.play()
+	}
+
+	return nil
+}
+
+func (p *Player) loadaudio(am *codec.AudioMetadata) error {
+	p.mu.Lock()
+	channels, downmix := p.channels, p.downmix
+	p.mu.Unlock()
+
+	if channels <= 0 {
+		channels = am.Channels
+	}
+
+	format, err := p.pb.load(am, codec.SupportedChannels(channels))
+	if err != nil {
+		return err
+	}
+
+	if err := p.codec.SetAudioFormat(format, downmix); err != nil {
+		p.pb.close()
+		return err
+	}
+
 	return nil
 }
 
+// VideoSize returns the size in pixels of the loaded video or cover art,
+// 0 when there is none
+func (p *Player) VideoSize() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.videow, p.videoh
+}
+
+// Path returns the path of the loaded media, empty when there is none
+func (p *Player) Path() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.path
+}
+
+// HasAudio reports whether the loaded media has an audio track
+func (p *Player) HasAudio() bool {
+	return p.codec.HasAudio()
+}
+
+// HasVideo reports whether the loaded media has a video track or cover art
+func (p *Player) HasVideo() bool {
+	return p.codec.HasVideo()
+}
+
 func (p *Player) Play() {
-	quit := make(chan struct{})
-	go p.codec.Parse(quit)
-	go p.Clock(quit)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.running || p.path == "" {
+		return
+	}
+	p.play()
+}
+
+// play starts the demux and clock goroutines, p.mu must be held
+func (p *Player) play() {
+	p.codec.AudioBuffer.Reset()
+	p.codec.VideoBuffer.Reset()
+	p.codec.SubtitleBuffer.Reset()
+
+	p.quit = make(chan struct{})
+	p.wg.Add(2)
+	go func(quit chan struct{}) {
+		defer p.wg.Done()
+		p.codec.Parse(quit)
+	}(p.quit)
+	go func(quit chan struct{}) {
+		defer p.wg.Done()
+		if p.codec.HasAudio() {
+			p.Clock(quit)
+		} else {
+			p.watchvideo(quit)
+		}
+	}(p.quit)
+
+	p.running = true
+	p.pb.pause(false)
+	p.pauseclocks(false)
+	p.state = StatePlaying
+}
+
+// Pause freezes the master clock and the audio device, the decoded
+// buffers are left as they are so Resume continues from the same spot
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != StatePlaying {
+		return
+	}
+
+	p.pb.pause(true)
+	p.pauseclocks(true)
+	p.state = StatePaused
+}
+
+func (p *Player) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state {
+	case StateIdle, StatePaused:
+		if p.path == "" {
+			return
+		}
+		if !p.running {
+			p.play()
+			return
+		}
+		p.pb.pause(false)
+		p.pauseclocks(false)
+		p.state = StatePlaying
+	case StateEnded:
+		p.codec.SeekSecond(0)
+		p.setclocks(0)
+		p.play()
+	}
+}
+
+func (p *Player) TogglePause() {
+	switch p.State() {
+	case StatePlaying:
+		p.Pause()
+	case StateIdle, StatePaused, StateEnded:
+		p.Resume()
+	}
+}
+
+// Stop halts the playback, joins the demux and clock goroutines and
+// rewinds to the start, the media stays loaded so Play starts it again
+func (p *Player) Stop() {
+	p.halt()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.path != "" {
+		p.codec.SeekSecond(0)
+	}
+	p.setclocks(0)
+	p.pauseclocks(true)
+	p.state = StateIdle
+}
+
+// halt joins the demux and clock goroutines and empties the buffers and
+// the audio device, the state and position are left to the caller
+func (p *Player) halt() {
+	p.mu.Lock()
+	if p.running {
+		close(p.quit)
+		p.codec.AudioBuffer.Close()
+		p.codec.VideoBuffer.Close()
+		p.codec.SubtitleBuffer.Close()
+		p.codec.AudioBuffer.Clear()
+		p.codec.VideoBuffer.Clear()
+		p.codec.SubtitleBuffer.Clear()
+		p.pb.pause(true)
+		p.pb.clear()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.running = false
+	p.skipping.Store(false)
+	p.pb.clear()
+	p.codec.AudioBuffer.Reset()
+	p.codec.VideoBuffer.Reset()
+	p.codec.SubtitleBuffer.Reset()
+}
+
+// Close stops the playback and releases the demuxer, the decoders and
+// the audio device, Load can be called again afterwards
+func (p *Player) Close() {
+	p.Stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.codec.Close()
+	p.pb.close()
+	p.path = ""
 }
 
+// SeekSecond seeks precisely to second
 func (p *Player) SeekSecond(second float32) {
-	if p.codec.Stopped {
-		p.Play()
+	p.Seek(second, codec.SeekPrecise)
+}
+
+// Seek moves to second, SeekKeyframe lands on the keyframe before it
+// which is faster for scrubbing. Landed tells the exact position once
+// the first frame is decoded.
+//
+// The seek itself is done by the demux goroutine between two reads, the
+// data decoded before it is told apart by its serial and dropped.
+func (p *Player) Seek(second float32, mode codec.SeekMode) {
+	p.mu.Lock()
+	prev := p.state
+	if p.path == "" || prev == StateLoading || prev == StateError {
+		p.mu.Unlock()
+		return
+	}
+	if prev == StateEnded {
+		prev = StatePlaying
+	}
+	p.state = StateSeeking
+	p.mu.Unlock()
+
+	p.codec.Seek(float64(second), mode)
+	p.setclocks(float64(second))
+	p.landed.Store(math.Float64bits(float64(second)))
+
+	// past EOF the demux goroutine is gone and the request waits for the
+	// next Parse, the playback has to be started again to get there
+	p.mu.Lock()
+	running := p.running
+	stale := running && !p.codec.Parsing()
+	p.mu.Unlock()
+
+	if stale {
+		p.halt()
 	}
-	p.codec.SeekSecond(second)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running && (stale || prev == StatePlaying) {
+		p.play()
+		if prev == StatePaused {
+			p.pb.pause(true)
+			p.pauseclocks(true)
+		}
+	}
+	p.state = prev
 }
 
-func (p *Player) Clock(quit chan struct{}) {
+// Clock feeds the audio device and drives the audio clock until the
+// audio buffer is drained or quit is closed, the audio is stretched to
+// follow the master when it is not the audio clock
+func (p *Player) Clock(quit <-chan struct{}) {
 	for {
 		select {
 		case <-quit:
 			return
 		default:
-			data := p.codec.AudioBuffer.Peek()
-			if data == nil {
-				continue
+		}
+
+		if p.skipping.Swap(false) {
+			p.skipaudio(math.Float64frombits(p.skip.Load()))
+		}
+
+		if p.State() == StatePaused {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+
+		p.land()
+
+		data, ok := p.codec.AudioBuffer.Peek()
+		if !ok {
+			break
+		}
+		if p.skipping.Load() {
+			continue
+		}
+
+		// decoded before the last seek
+		if data.Serial != p.codec.Serial() {
+			p.codec.AudioBuffer.TryPop()
+			continue
+		}
+
+		samples := p.syncaudio(p.speedaudio(data.Samples))
+		volume := p.Volume()
+		if p.Muted() {
+			volume = 0
+		}
+		if !p.pb.play(samples, volume, quit) {
+			continue
+		}
+		// a step came in while the chunk was queued, the skip clears the
+		// device and drops the chunk if it is before the step
+		if p.skipping.Load() {
+			continue
+		}
+
+		// what is heard is behind the end of this chunk by whatever SDL
+		// still holds, the clock interpolates from there
+		end := data.PTS + data.Duration
+		p.audclk.set(end - p.pb.queued()*p.Speed())
+		p.extclk.follow(p.audclk)
+		p.codec.AudioBuffer.TryPop()
+	}
+
+	p.end(quit)
+}
+
+// watchvideo ends the playback once the video is drained when there is
+// no audio, the clocks run on their own in the meantime
+func (p *Player) watchvideo(quit <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			if p.codec.VideoBuffer.Drained() {
+				p.end(quit)
+				return
 			}
+		}
+	}
+}
+
+// end marks the playback as ended unless it was stopped through quit,
+// or starts it over while there are loops left
+func (p *Player) end(quit <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-quit:
+		// Stop takes care of the state
+		return
+	default:
+	}
+
+	p.running = false
 
-			p.pb.play(data.Samples, p.Volume)
-			p.clock.set(data.PTS)
-			p.codec.AudioBuffer.Pop()
+	// the demuxer is done too, the buffers are closed and drained
+	if p.loops != 0 {
+		if p.loops > 0 {
+			p.loops--
 		}
+		p.codec.SeekSecond(0)
+		p.setclocks(0)
+		p.play()
+		return
 	}
+
+	p.pauseclocks(true)
+	p.state = StateEnded
 }
 
+// LatestFrame returns the frame due at the current position, empty when
+// the one on screen stays. The caller owns the frame and releases it once
+// its planes are uploaded.
 func (p *Player) LatestFrame() codec.VideoData {
-	if p.codec.Stopped {
+	if p.State() == StatePaused {
+		if p.stepping.Load() {
+			return p.stepframe()
+		}
+		return codec.VideoData{}
+	}
+	f, ok := p.codec.VideoBuffer.TryPeek()
+	if !ok {
 		return codec.VideoData{}
 	}
-	f := p.codec.VideoBuffer.Peek()
 
-	if f != nil {
-		master := p.clock.get()
-		diff := f.PTS - master
+	// decoded before the last seek
+	if f.Serial != p.codec.Serial() {
+		p.dropframe()
+		return codec.VideoData{}
+	}
 
-		if diff > 0.5 {
-			p.codec.VideoBuffer.Pop()
-			return codec.VideoData{}
-		}
+	p.land()
+
+	if f.Cover {
+		p.codec.VideoBuffer.TryPop()
+		return f
+	}
+
+	master := p.masterclock().get()
+	diff := f.PTS - master
+
+	if diff > 0.5 {
+		p.dropframe()
+		return codec.VideoData{}
+	}
+
+	if diff > 0 {
+		return codec.VideoData{}
+	}
+
+	if diff < -0.05 {
+		p.dropframe()
+		return codec.VideoData{}
+	}
+
+	p.showed(f.PTS)
+
+	p.codec.VideoBuffer.TryPop()
+	return f
+}
+
+// dropframe pops the frame that was peeked and gives its planes back to
+// the pool
+func (p *Player) dropframe() {
+	if f, ok := p.codec.VideoBuffer.TryPop(); ok {
+		f.Release()
+	}
+}
+
+// PoolStats returns the counters of the decoded frame pool
+func (p *Player) PoolStats() codec.PoolStats {
+	return p.codec.PoolStats()
+}
 
-		if diff > 0 {
-			return codec.VideoData{}
+// SetFrameAllocator makes the decoded frames land in buffers from a, the
+// renderer's mapped ones for instance
+func (p *Player) SetFrameAllocator(a codec.Allocator) {
+	p.codec.SetFrameAllocator(a)
+}
+
+// Subtitle returns the text of the subtitle due at the current position,
+// empty when there is none
+func (p *Player) Subtitle() string {
+	now := p.masterclock().get()
+
+	for {
+		s, ok := p.codec.SubtitleBuffer.TryPeek()
+		if !ok {
+			return ""
 		}
 
-		if diff < -0.05 {
-			p.codec.VideoBuffer.Pop()
-			return codec.VideoData{}
+		// decoded before the last seek, or over
+		if s.Serial != p.codec.Serial() || s.End < now {
+			p.codec.SubtitleBuffer.TryPop()
+			continue
 		}
 
-		newFrame := codec.VideoData{
-			PTS:  f.PTS,
-			H:    f.H,
-			W:    f.W,
-			Data: f.Data,
+		if s.Start > now {
+			return ""
 		}
+		return s.Text
+	}
+}
 
-		p.codec.VideoBuffer.Pop()
-		return newFrame
+// land moves the clocks to where the last seek actually landed
+func (p *Player) land() {
+	if pos, ok := p.codec.Landed(); ok {
+		p.setclocks(pos)
+		p.landed.Store(math.Float64bits(pos))
 	}
+}
+
+// Landed returns the position the last seek landed on, it is the
+// requested one until the first frame after the seek is decoded
+func (p *Player) Landed() float32 {
+	return float32(math.Float64frombits(p.landed.Load()))
+}
+
+// showed updates the video clock with the pts of the frame on screen.
+// As the master the video clock runs freely and is only pulled back
+// when it drifted off the frames, resetting it on every frame would make
+// it lag by the render loop latency.
+func (p *Player) showed(pts float64) {
+	p.shown.Store(math.Float64bits(pts))
+
+	if p.codec.HasAudio() {
+		p.avoffset.Store(math.Float64bits(p.audclk.get() - pts))
+	}
+
+	if p.MasterClock() != MasterVideo {
+		p.vidclk.set(pts)
+		return
+	}
+
+	if math.Abs(p.vidclk.get()-pts) > viddrift {
+		p.vidclk.set(pts)
+	}
+	p.extclk.follow(p.vidclk)
+}
 
-	return codec.VideoData{}
+// AVOffset returns how far in seconds the audio being heard was ahead of
+// the last frame shown, when it was shown. It stays 0 without audio.
+func (p *Player) AVOffset() float64 {
+	return math.Float64frombits(p.avoffset.Load())
 }
 
 func (p *Player) GetSecond() float32 {
-	return float32(p.clock.get())
+	return float32(p.masterclock().get())
 }
@@ -0,0 +1,33 @@
+package player
+
+type State int
+
+const (
+	StateIdle State = iota
+	StateLoading
+	StatePlaying
+	StatePaused
+	StateSeeking
+	StateEnded
+	StateError
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateLoading:
+		return "loading"
+	case StatePlaying:
+		return "playing"
+	case StatePaused:
+		return "paused"
+	case StateSeeking:
+		return "seeking"
+	case StateEnded:
+		return "ended"
+	case StateError:
+		return "error"
+	}
+	return "unknown"
+}
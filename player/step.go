@@ -0,0 +1,97 @@
+package player
+
+import (
+	"math"
+
+	"GoldenFealla/go-video-player/codec"
+)
+
+// fallbackfps is assumed for streams that don't tell their frame rate
+const fallbackfps = 25
+
+// StepFrame pauses and shows the next frame, the clocks move to it
+func (p *Player) StepFrame() {
+	if !p.HasVideo() || p.codec.HasCover() {
+		return
+	}
+
+	p.Pause()
+	if p.State() != StatePaused {
+		return
+	}
+	p.stepping.Store(true)
+}
+
+// StepBackFrame pauses and shows the frame before the one on screen, it
+// seeks precisely to it as decoders only go forward
+func (p *Player) StepBackFrame() {
+	if !p.HasVideo() || p.codec.HasCover() {
+		return
+	}
+
+	// at the end the seek below starts the playback again, it is paused
+	// once it did
+	ended := p.State() == StateEnded
+	p.Pause()
+	if !ended && p.State() != StatePaused {
+		return
+	}
+
+	dur := p.codec.FrameDuration()
+	if dur <= 0 {
+		dur = 1.0 / fallbackfps
+	}
+
+	// half a frame back lands inside the previous one
+	target := max(math.Float64frombits(p.shown.Load())-dur/2, 0)
+	p.Seek(float32(target), codec.SeekPrecise)
+	if ended {
+		p.Pause()
+	}
+	p.stepping.Store(true)
+}
+
+// stepframe hands out the frame a step waits for, the audio before it is
+// dropped so resuming starts from there
+func (p *Player) stepframe() codec.VideoData {
+	f, ok := p.codec.VideoBuffer.TryPeek()
+	if !ok {
+		return codec.VideoData{}
+	}
+
+	// decoded before the last seek
+	if f.Serial != p.codec.Serial() {
+		p.dropframe()
+		return codec.VideoData{}
+	}
+
+	p.land()
+	p.codec.VideoBuffer.TryPop()
+	p.stepping.Store(false)
+
+	p.setclocks(f.PTS)
+	if p.codec.HasAudio() {
+		p.skip.Store(math.Float64bits(f.PTS))
+		p.skipping.Store(true)
+	}
+
+	return f
+}
+
+// skipaudio drops the audio that ends before pts, along with what the
+// device still holds. It runs on the Clock goroutine, the only one taking
+// from the audio buffer, see skipping.
+func (p *Player) skipaudio(pts float64) {
+	p.pb.clear()
+
+	for {
+		data, ok := p.codec.AudioBuffer.TryPeek()
+		if !ok {
+			return
+		}
+		if data.Serial == p.codec.Serial() && data.PTS+data.Duration > pts {
+			return
+		}
+		p.codec.AudioBuffer.TryPop()
+	}
+}
@@ -0,0 +1,159 @@
+package player
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"GoldenFealla/go-video-player/codec"
+
+	"github.com/asticode/go-astiav"
+)
+
+const (
+	// number of chunks the audio drift is averaged over
+	driftchunks = 20
+	// the most a chunk is stretched or squeezed, in percent
+	maxstretch = 10
+)
+
+// audiosync averages the drift of the audio against the master clock
+// and decides how many samples a chunk should last to catch up, the
+// same way synchronize_audio does in ffplay
+type audiosync struct {
+	mu    sync.Mutex
+	cum   float64
+	count int
+}
+
+func (s *audiosync) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cum = 0
+	s.count = 0
+}
+
+// wanted returns the frame count a chunk of n frames should be played
+// as, diff is how far the audio is ahead of the master and threshold
+// the drift in seconds that is tolerated
+func (s *audiosync) wanted(n int, diff, threshold float64, rate int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if math.IsNaN(diff) || math.Abs(diff) >= nosync {
+		// too far off to correct, the clocks get snapped instead
+		s.cum, s.count = 0, 0
+		return n
+	}
+
+	coef := math.Exp(math.Log(0.01) / driftchunks)
+	s.cum = diff + coef*s.cum
+	if s.count < driftchunks {
+		s.count++
+		return n
+	}
+
+	if avg := s.cum * (1 - coef); math.Abs(avg) < threshold {
+		return n
+	}
+
+	wanted := n + int(diff*float64(rate))
+	lo := n * (100 - maxstretch) / 100
+	hi := n * (100 + maxstretch) / 100
+	return min(max(wanted, lo), hi)
+}
+
+// stretch resamples interleaved samples of format to wanted frames with
+// linear interpolation, good enough for the few percent drift correction
+// needs
+func stretch(samples []byte, format codec.AudioFormat, wanted int) []byte {
+	bps := format.SampleFormat.BytesPerSample()
+	if bps <= 0 || format.Channels <= 0 {
+		return samples
+	}
+
+	framesize := bps * format.Channels
+	n := len(samples) / framesize
+	if n < 2 || wanted <= 0 || wanted == n {
+		return samples
+	}
+
+	read := func(frame, ch int) float64 {
+		i := frame*framesize + ch*bps
+		switch format.SampleFormat {
+		case astiav.SampleFormatS16:
+			return float64(int16(binary.LittleEndian.Uint16(samples[i:])))
+		case astiav.SampleFormatFlt:
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(samples[i:])))
+		}
+		return 0
+	}
+
+	out := make([]byte, wanted*framesize)
+	write := func(frame, ch int, v float64) {
+		i := frame*framesize + ch*bps
+		switch format.SampleFormat {
+		case astiav.SampleFormatS16:
+			binary.LittleEndian.PutUint16(out[i:], uint16(int16(math.Round(v))))
+		case astiav.SampleFormatFlt:
+			binary.LittleEndian.PutUint32(out[i:], math.Float32bits(float32(v)))
+		}
+	}
+
+	step := float64(n-1) / float64(max(wanted-1, 1))
+	for j := range wanted {
+		pos := float64(j) * step
+		k := min(int(pos), n-2)
+		t := pos - float64(k)
+		for ch := range format.Channels {
+			a, b := read(k, ch), read(k+1, ch)
+			write(j, ch, a+(b-a)*t)
+		}
+	}
+
+	return out
+}
+
+// speedaudio resamples the next chunk to last as long as it plays at the
+// playback speed
+func (p *Player) speedaudio(samples []byte) []byte {
+	speed := p.Speed()
+	if speed == 1 {
+		return samples
+	}
+
+	format := p.pb.format
+	framesize := format.SampleFormat.BytesPerSample() * format.Channels
+	if framesize <= 0 {
+		return samples
+	}
+
+	n := len(samples) / framesize
+	return stretch(samples, format, int(math.Round(float64(n)/speed)))
+}
+
+// syncaudio stretches the next chunk so the audio follows the master
+// clock, it is a no-op when the audio is the master
+func (p *Player) syncaudio(samples []byte) []byte {
+	master := p.MasterClock()
+	if master == MasterAudio {
+		return samples
+	}
+
+	format := p.pb.format
+	framesize := format.SampleFormat.BytesPerSample() * format.Channels
+	if framesize <= 0 {
+		return samples
+	}
+
+	diff := p.audclk.get() - p.masterclock().get()
+	threshold := float64(p.pb.target) / 1000
+	n := len(samples) / framesize
+
+	wanted := p.async.wanted(n, diff, threshold, format.SampleRate)
+
+	if wanted == n {
+		return samples
+	}
+	return stretch(samples, format, wanted)
+}
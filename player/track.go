@@ -0,0 +1,94 @@
+package player
+
+import (
+	"errors"
+
+	"GoldenFealla/go-video-player/codec"
+)
+
+// Tracks lists every stream of the loaded media
+func (p *Player) Tracks() []codec.Track {
+	return p.codec.Tracks()
+}
+
+func (p *Player) AudioTrack() int {
+	return p.codec.AudioTrack()
+}
+
+func (p *Player) VideoTrack() int {
+	return p.codec.VideoTrack()
+}
+
+func (p *Player) SubtitleTrack() int {
+	return p.codec.SubtitleTrack()
+}
+
+// SelectAudioTrack switches to the audio stream at index, playback goes
+// on from the current position. Media loaded without audio gets its
+// device opened for the track.
+func (p *Player) SelectAudioTrack(index int) error {
+	return p.switchtrack(func() error {
+		am, err := p.codec.SelectAudioTrack(index)
+		if err != nil {
+			return err
+		}
+		if !p.pb.opened() {
+			return p.loadaudio(am)
+		}
+		return nil
+	})
+}
+
+// SelectVideoTrack switches to the video stream at index, playback goes
+// on from the current position
+func (p *Player) SelectVideoTrack(index int) error {
+	return p.switchtrack(func() error {
+		_, err := p.codec.SelectVideoTrack(index)
+		return err
+	})
+}
+
+// SelectSubtitleTrack switches to the subtitle stream at index, -1
+// turns subtitles off
+func (p *Player) SelectSubtitleTrack(index int) error {
+	return p.switchtrack(func() error {
+		return p.codec.SelectSubtitleTrack(index)
+	})
+}
+
+// switchtrack stops the goroutines, runs fn with the decoders idle, then
+// seeks back and restarts in the state the player was in
+func (p *Player) switchtrack(fn func() error) error {
+	p.mu.Lock()
+	if p.path == "" {
+		p.mu.Unlock()
+		return errors.New("player: nothing loaded")
+	}
+	prev, running := p.state, p.running
+	p.mu.Unlock()
+
+	pos := p.GetSecond()
+	p.halt()
+
+	err := fn()
+
+	p.codec.SeekSecond(pos)
+	p.setclocks(float64(pos))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !running {
+		p.state = prev
+		return err
+	}
+
+	p.play()
+	if prev == StatePaused {
+		p.pb.pause(true)
+		p.pauseclocks(true)
+		p.state = StatePaused
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+package playlist
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrFormat is returned for files that aren't a playlist format we know
+var ErrFormat = errors.New("playlist: unknown format")
+
+// ErrHLS is returned for M3U8 files that are HLS playlists, they list the
+// segments of a single stream and are played as one media
+var ErrHLS = errors.New("playlist: HLS stream")
+
+// IsPlaylist reports whether path is a local file with the extension of
+// a playlist. URLs never are, an .m3u8 one is most likely an HLS stream
+// and is left to the demuxer.
+func IsPlaylist(path string) bool {
+	if isurl(path) {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	}
+	return false
+}
+
+// Load reads the playlist file at path, the format is told by the
+// extension. It returns ErrHLS for HLS playlists.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("playlist: opening %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		return ReadM3U(f, dir)
+	case ".pls":
+		return ReadPLS(f, dir)
+	}
+	return nil, ErrFormat
+}
+
+// Save writes entries to path in the format told by the extension, .m3u
+// is plain M3U and .m3u8 extended M3U
+func Save(path string, entries []Entry) error {
+	var write func(*os.File) error
+
+	dir := filepath.Dir(path)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u":
+		write = func(f *os.File) error { return WriteM3U(f, entries, dir, false) }
+	case ".m3u8":
+		write = func(f *os.File) error { return WriteM3U(f, entries, dir, true) }
+	case ".pls":
+		write = func(f *os.File) error { return WritePLS(f, entries, dir) }
+	default:
+		return ErrFormat
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("playlist: creating %s failed: %w", path, err)
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func isurl(path string) bool {
+	return strings.Contains(path, "://")
+}
+
+// resolve makes path relative to dir absolute, URLs are left alone
+func resolve(path, dir string) string {
+	if isurl(path) || filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+// relative returns path relative to dir when it lies under it
+func relative(path, dir string) string {
+	if isurl(path) || dir == "" {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	absdir, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(absdir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// seconds rounds a duration for the playlist files, -1 when unknown
+func seconds(d float64) int {
+	if d < 0 || math.IsNaN(d) {
+		return -1
+	}
+	return int(math.Round(d))
+}
@@ -0,0 +1,90 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadM3U reads a plain or extended M3U playlist, relative paths are
+// resolved against dir. The text is read as UTF-8 whatever the
+// extension, as about every M3U around is. A playlist with #EXT-X- tags
+// is an HLS one and gives ErrHLS.
+func ReadM3U(r io.Reader, dir string) ([]Entry, error) {
+	var entries []Entry
+
+	// the #EXTINF line describes the path that comes next
+	next := NewEntry("")
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			dur, title := extinf(strings.TrimPrefix(line, "#EXTINF:"))
+			// attributes may follow the duration, e.g. tvg-id="..."
+			dur, _, _ = strings.Cut(strings.TrimSpace(dur), " ")
+			if d, err := strconv.ParseFloat(dur, 64); err == nil && d >= 0 {
+				next.Duration = d
+			}
+			next.Title = strings.TrimSpace(title)
+		case strings.HasPrefix(line, "#EXT-X-"):
+			return nil, ErrHLS
+		case strings.HasPrefix(line, "#"):
+			// #EXTM3U and directives we don't use
+		default:
+			next.Path = resolve(line, dir)
+			entries = append(entries, next)
+			next = NewEntry("")
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("playlist: reading m3u failed: %w", err)
+	}
+	return entries, nil
+}
+
+// extinf splits the info of an #EXTINF line at the comma before the
+// title, commas inside quoted attribute values don't count
+func extinf(info string) (dur, title string) {
+	quoted := false
+	for i, r := range info {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			return info[:i], info[i+1:]
+		}
+	}
+	return info, ""
+}
+
+// WriteM3U writes entries as M3U, with the #EXTINF lines carrying titles
+// and durations when extended. Paths under dir are written relative to
+// it.
+func WriteM3U(w io.Writer, entries []Entry, dir string, extended bool) error {
+	bw := bufio.NewWriter(w)
+
+	if extended {
+		fmt.Fprintln(bw, "#EXTM3U")
+	}
+	for _, e := range entries {
+		if extended {
+			fmt.Fprintf(bw, "#EXTINF:%d,%s\n", seconds(e.Duration), e.Title)
+		}
+		fmt.Fprintln(bw, relative(e.Path, dir))
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("playlist: writing m3u failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package playlist
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadM3U(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "abs.mp3")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []Entry
+		wanterr error
+	}{
+		{
+			name:  "plain",
+			input: "a.mp3\nsub/b.mp3\n" + abs + "\nhttp://example.com/c.mp3\n",
+			want: []Entry{
+				NewEntry(filepath.Join(dir, "a.mp3")),
+				NewEntry(filepath.Join(dir, "sub", "b.mp3")),
+				NewEntry(abs),
+				NewEntry("http://example.com/c.mp3"),
+			},
+		},
+		{
+			name:  "extended",
+			input: "#EXTM3U\n#EXTINF:123,Artist - Song\nsong.mp3\n#EXTINF:-1,Live\nhttp://example.com/live\n",
+			want: []Entry{
+				{Path: filepath.Join(dir, "song.mp3"), Title: "Artist - Song", Duration: 123},
+				{Path: "http://example.com/live", Title: "Live", Duration: -1},
+			},
+		},
+		{
+			name:  "bom and crlf",
+			input: "\ufeff#EXTM3U\r\n#EXTINF:5.5,Short\r\n\r\nshort.ogg\r\n",
+			want:  []Entry{{Path: filepath.Join(dir, "short.ogg"), Title: "Short", Duration: 5.5}},
+		},
+		{
+			name:  "bom on a plain path",
+			input: "\ufeffa.mp3\n",
+			want:  []Entry{NewEntry(filepath.Join(dir, "a.mp3"))},
+		},
+		{
+			name:  "extinf attributes",
+			input: "#EXTM3U\n#EXTINF:-1 tvg-id=\"one\" group-title=\"News, World\",Channel One\nhttp://example.com/1\n",
+			want:  []Entry{{Path: "http://example.com/1", Title: "Channel One", Duration: -1}},
+		},
+		{
+			name:  "extinf without title",
+			input: "#EXTINF:30\nx.mp3\n",
+			want:  []Entry{{Path: filepath.Join(dir, "x.mp3"), Duration: 30}},
+		},
+		{
+			name:  "extinf applies to the next path only",
+			input: "#EXTINF:30,X\nx.mp3\ny.mp3\n",
+			want: []Entry{
+				{Path: filepath.Join(dir, "x.mp3"), Title: "X", Duration: 30},
+				NewEntry(filepath.Join(dir, "y.mp3")),
+			},
+		},
+		{
+			name:  "comments and directives",
+			input: "#EXTM3U\n# a comment\n#PLAYLIST:Mine\n#EXTGRP:Group\na.mp3\n",
+			want:  []Entry{NewEntry(filepath.Join(dir, "a.mp3"))},
+		},
+		{
+			name:  "empty",
+			input: "#EXTM3U\n",
+		},
+		{
+			name:    "hls",
+			input:   "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4,\nseg0.ts\n",
+			wanterr: ErrHLS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadM3U(strings.NewReader(tt.input), dir)
+			if !errors.Is(err, tt.wanterr) {
+				t.Fatalf("error %v, want %v", err, tt.wanterr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteM3U(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(filepath.Dir(dir), "outside.mkv")
+
+	entries := []Entry{
+		{Path: filepath.Join(dir, "sub", "a.mkv"), Title: "A", Duration: 61.4},
+		{Path: outside, Duration: -1},
+		{Path: "https://example.com/b.mp4", Title: "B", Duration: 10},
+	}
+
+	tests := []struct {
+		name     string
+		extended bool
+		want     string
+		// what reading it back gives
+		read []Entry
+	}{
+		{
+			name: "plain",
+			want: "sub/a.mkv\n" + outside + "\nhttps://example.com/b.mp4\n",
+			read: []Entry{
+				NewEntry(entries[0].Path),
+				NewEntry(outside),
+				NewEntry(entries[2].Path),
+			},
+		},
+		{
+			name:     "extended",
+			extended: true,
+			want: "#EXTM3U\n" +
+				"#EXTINF:61,A\nsub/a.mkv\n" +
+				"#EXTINF:-1,\n" + outside + "\n" +
+				"#EXTINF:10,B\nhttps://example.com/b.mp4\n",
+			read: []Entry{
+				{Path: entries[0].Path, Title: "A", Duration: 61},
+				NewEntry(outside),
+				entries[2],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteM3U(&buf, entries, dir, tt.extended); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("wrote\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+
+			got, err := ReadM3U(&buf, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.read) {
+				t.Fatalf("read back %v, want %v", got, tt.read)
+			}
+		})
+	}
+}
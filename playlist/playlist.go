@@ -0,0 +1,357 @@
+package playlist
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Entry is one media of a playlist
+type Entry struct {
+	// a file path or a URL
+	Path string
+	// empty when the playlist file didn't tell
+	Title string
+	// in seconds, negative when unknown
+	Duration float64
+}
+
+// NewEntry makes an entry for path with nothing else known about it
+func NewEntry(path string) Entry {
+	return Entry{Path: path, Duration: -1}
+}
+
+// Name returns the title, or the file name when there is none
+func (e Entry) Name() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return filepath.Base(e.Path)
+}
+
+// Repeat is what happens past the ends of the playlist
+type Repeat int
+
+const (
+	// RepeatOff stops after the last entry
+	RepeatOff Repeat = iota
+	// RepeatOne plays the current entry again when it ends
+	RepeatOne
+	// RepeatAll starts over from the first entry after the last
+	RepeatAll
+)
+
+func (r Repeat) String() string {
+	switch r {
+	case RepeatOff:
+		return "off"
+	case RepeatOne:
+		return "one"
+	case RepeatAll:
+		return "all"
+	}
+	return "unknown"
+}
+
+type item struct {
+	id uint64
+	Entry
+}
+
+// Playlist is an ordered queue of entries and the one playing.
+//
+// Entries are addressed by their index in the list as Entries returns
+// it. Shuffling only changes the order they are played in, the list
+// keeps its order.
+type Playlist struct {
+	mu sync.Mutex
+
+	items  []item
+	nextid uint64
+
+	// id of the entry playing, 0 for none
+	current uint64
+	// where in the play order the current entry was when it got removed,
+	// -1 otherwise. Next then plays the one that took its place.
+	gap int
+
+	repeat  Repeat
+	shuffle bool
+	// play order when shuffled, ids of every item
+	order []uint64
+}
+
+func New() *Playlist {
+	return &Playlist{
+		nextid: 1,
+		gap:    -1,
+	}
+}
+
+// sequence returns the ids in play order, pl.mu must be held
+func (pl *Playlist) sequence() []uint64 {
+	if pl.shuffle {
+		return pl.order
+	}
+
+	seq := make([]uint64, len(pl.items))
+	for i, it := range pl.items {
+		seq[i] = it.id
+	}
+	return seq
+}
+
+// index returns the position of id in the list, -1 if it isn't there
+func (pl *Playlist) index(id uint64) int {
+	return slices.IndexFunc(pl.items, func(it item) bool { return it.id == id })
+}
+
+func (pl *Playlist) entry(id uint64) Entry {
+	return pl.items[pl.index(id)].Entry
+}
+
+// Add appends entries to the list, shuffled in among the ones not played
+// yet when shuffling
+func (pl *Playlist) Add(entries ...Entry) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	for _, e := range entries {
+		it := item{id: pl.nextid, Entry: e}
+		pl.nextid++
+		pl.items = append(pl.items, it)
+
+		if pl.shuffle {
+			after := slices.Index(pl.order, pl.current) + 1
+			at := after + rand.IntN(len(pl.order)-after+1)
+			pl.order = slices.Insert(pl.order, at, it.id)
+		}
+	}
+}
+
+// Remove drops the entry at index i, the current one keeps playing and
+// Next goes on with what follows it
+func (pl *Playlist) Remove(i int) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if i < 0 || i >= len(pl.items) {
+		return
+	}
+
+	id := pl.items[i].id
+	if id == pl.current {
+		pl.gap = slices.Index(pl.sequence(), id)
+		pl.current = 0
+	} else if pl.current == 0 && pl.gap > slices.Index(pl.sequence(), id) {
+		pl.gap--
+	}
+
+	pl.items = slices.Delete(pl.items, i, i+1)
+	if pl.shuffle {
+		pl.order = slices.DeleteFunc(pl.order, func(o uint64) bool { return o == id })
+	}
+}
+
+// Move puts the entry at index from at index to, shifting the ones in
+// between. The play order follows the list, except when shuffled where
+// it is left as it is.
+func (pl *Playlist) Move(from, to int) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if from < 0 || from >= len(pl.items) || to < 0 || to >= len(pl.items) || from == to {
+		return
+	}
+
+	// the gap goes along with the entry that took the place of the
+	// removed one
+	var after uint64
+	if !pl.shuffle && pl.gap >= 0 && pl.gap < len(pl.items) {
+		after = pl.items[pl.gap].id
+	}
+
+	it := pl.items[from]
+	pl.items = slices.Delete(pl.items, from, from+1)
+	pl.items = slices.Insert(pl.items, to, it)
+
+	if after != 0 {
+		pl.gap = pl.index(after)
+	}
+}
+
+// Clear empties the list
+func (pl *Playlist) Clear() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.items = nil
+	pl.order = nil
+	pl.current = 0
+	pl.gap = -1
+}
+
+// Len returns the number of entries
+func (pl *Playlist) Len() int {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return len(pl.items)
+}
+
+// Entries returns a copy of the list
+func (pl *Playlist) Entries() []Entry {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	entries := make([]Entry, len(pl.items))
+	for i, it := range pl.items {
+		entries[i] = it.Entry
+	}
+	return entries
+}
+
+// Current returns the index of the entry playing, -1 for none
+func (pl *Playlist) Current() int {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.current == 0 {
+		return -1
+	}
+	return pl.index(pl.current)
+}
+
+// Jump makes the entry at index i the current one and returns it
+func (pl *Playlist) Jump(i int) (Entry, bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if i < 0 || i >= len(pl.items) {
+		return Entry{}, false
+	}
+
+	pl.current = pl.items[i].id
+	pl.gap = -1
+	return pl.items[i].Entry, true
+}
+
+// Next moves to the entry after the current one and returns it, false
+// past the last one unless repeating all
+func (pl *Playlist) Next() (Entry, bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.step(1)
+}
+
+// Prev moves to the entry before the current one and returns it, false
+// before the first one unless repeating all
+func (pl *Playlist) Prev() (Entry, bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.step(-1)
+}
+
+// Advance moves on once the current entry ended, it stays on it when
+// repeating one
+func (pl *Playlist) Advance() (Entry, bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.repeat == RepeatOne && pl.current != 0 {
+		return pl.entry(pl.current), true
+	}
+	return pl.step(1)
+}
+
+// step moves by one in play order, pl.mu must be held
+func (pl *Playlist) step(dir int) (Entry, bool) {
+	seq := pl.sequence()
+	if len(seq) == 0 {
+		return Entry{}, false
+	}
+
+	var i int
+	switch {
+	case pl.current != 0:
+		i = slices.Index(seq, pl.current) + dir
+	case pl.gap >= 0 && dir > 0:
+		// what took the place of the removed entry
+		i = pl.gap
+	case pl.gap >= 0:
+		i = pl.gap - 1
+	case dir > 0:
+		i = 0
+	default:
+		return Entry{}, false
+	}
+
+	if i < 0 || i >= len(seq) {
+		if pl.repeat != RepeatAll {
+			return Entry{}, false
+		}
+		if i >= len(seq) && pl.shuffle {
+			// a new round, in a new order
+			pl.reshuffle(0)
+			seq = pl.order
+		}
+		i = (i + len(seq)) % len(seq)
+	}
+
+	pl.current = seq[i]
+	pl.gap = -1
+	return pl.entry(pl.current), true
+}
+
+// reshuffle makes a new play order with first in front, 0 for none,
+// pl.mu must be held
+func (pl *Playlist) reshuffle(first uint64) {
+	pl.order = pl.order[:0]
+	for _, it := range pl.items {
+		pl.order = append(pl.order, it.id)
+	}
+	rand.Shuffle(len(pl.order), func(i, j int) {
+		pl.order[i], pl.order[j] = pl.order[j], pl.order[i]
+	})
+
+	if i := slices.Index(pl.order, first); i > 0 {
+		pl.order[0], pl.order[i] = pl.order[i], pl.order[0]
+	}
+}
+
+// SetShuffle turns shuffling on or off, the current entry comes first in
+// the shuffled order so every other one plays after it
+func (pl *Playlist) SetShuffle(on bool) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if on == pl.shuffle {
+		return
+	}
+
+	pl.shuffle = on
+	pl.gap = -1
+	if on {
+		pl.reshuffle(pl.current)
+	} else {
+		pl.order = nil
+	}
+}
+
+func (pl *Playlist) Shuffle() bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.shuffle
+}
+
+func (pl *Playlist) SetRepeat(r Repeat) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.repeat = r
+}
+
+func (pl *Playlist) Repeat() Repeat {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.repeat
+}
@@ -0,0 +1,336 @@
+package playlist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newlist makes a playlist of entries named after paths
+func newlist(paths ...string) *Playlist {
+	pl := New()
+	for _, p := range paths {
+		pl.Add(NewEntry(p))
+	}
+	return pl
+}
+
+func paths(entries []Entry) []string {
+	ps := make([]string, len(entries))
+	for i, e := range entries {
+		ps[i] = e.Path
+	}
+	return ps
+}
+
+// run applies one of next, prev and advance and returns the path it
+// moved to, empty when it didn't
+func run(t *testing.T, pl *Playlist, op string) string {
+	t.Helper()
+
+	var e Entry
+	var ok bool
+	switch op {
+	case "next":
+		e, ok = pl.Next()
+	case "prev":
+		e, ok = pl.Prev()
+	case "advance":
+		e, ok = pl.Advance()
+	default:
+		t.Fatalf("unknown op %q", op)
+	}
+	if !ok {
+		return ""
+	}
+	return e.Path
+}
+
+func TestPlaylistStep(t *testing.T) {
+	tests := []struct {
+		name   string
+		repeat Repeat
+		ops    []string
+		want   []string
+	}{
+		{"next to the end", RepeatOff, []string{"next", "next", "next", "next"}, []string{"a", "b", "c", ""}},
+		{"next wraps", RepeatAll, []string{"next", "next", "next", "next"}, []string{"a", "b", "c", "a"}},
+		{"prev without current", RepeatOff, []string{"prev"}, []string{""}},
+		{"prev before the first", RepeatOff, []string{"next", "prev"}, []string{"a", ""}},
+		{"prev wraps", RepeatAll, []string{"next", "prev"}, []string{"a", "c"}},
+		{"prev and next", RepeatOff, []string{"next", "next", "prev", "next"}, []string{"a", "b", "a", "b"}},
+		{"advance to the end", RepeatOff, []string{"advance", "advance", "advance", "advance"}, []string{"a", "b", "c", ""}},
+		{"advance repeats one", RepeatOne, []string{"next", "advance", "advance", "next"}, []string{"a", "a", "a", "b"}},
+		{"advance repeats all", RepeatAll, []string{"next", "next", "next", "advance"}, []string{"a", "b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := newlist("a", "b", "c")
+			pl.SetRepeat(tt.repeat)
+
+			for i, op := range tt.ops {
+				if got := run(t, pl, op); got != tt.want[i] {
+					t.Fatalf("op %d %s got %q, want %q", i, op, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlaylistStepEmpty(t *testing.T) {
+	pl := New()
+	pl.SetRepeat(RepeatAll)
+
+	for _, op := range []string{"next", "prev", "advance"} {
+		if got := run(t, pl, op); got != "" {
+			t.Fatalf("%s on an empty list got %q", op, got)
+		}
+	}
+	if pl.Current() != -1 {
+		t.Fatalf("current %d on an empty list", pl.Current())
+	}
+}
+
+func TestPlaylistJump(t *testing.T) {
+	pl := newlist("a", "b", "c")
+
+	if _, ok := pl.Jump(3); ok {
+		t.Fatal("jump past the end succeeded")
+	}
+	if _, ok := pl.Jump(-1); ok {
+		t.Fatal("jump before the start succeeded")
+	}
+
+	e, ok := pl.Jump(1)
+	if !ok || e.Path != "b" || pl.Current() != 1 {
+		t.Fatalf("jump got %q %v, current %d", e.Path, ok, pl.Current())
+	}
+	if got := run(t, pl, "next"); got != "c" {
+		t.Fatalf("next after jump got %q", got)
+	}
+}
+
+func TestPlaylistRemove(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		removes []int
+		repeat  Repeat
+		op      string
+		want    string
+		// index of the current entry after the removals
+		wantcur int
+	}{
+		{"before the current", 1, []int{0}, RepeatOff, "next", "c", 0},
+		{"after the current", 1, []int{2}, RepeatOff, "next", "d", 1},
+		{"the current, next", 1, []int{1}, RepeatOff, "next", "c", -1},
+		{"the current, prev", 1, []int{1}, RepeatOff, "prev", "a", -1},
+		{"the current then one before", 1, []int{1, 0}, RepeatOff, "next", "c", -1},
+		{"the current then the next", 1, []int{1, 1}, RepeatOff, "next", "d", -1},
+		{"the current then one after", 1, []int{1, 2}, RepeatOff, "next", "c", -1},
+		{"the last while current", 3, []int{3}, RepeatOff, "next", "", -1},
+		{"the last while current, repeat", 3, []int{3}, RepeatAll, "next", "a", -1},
+		{"the first while current, prev", 0, []int{0}, RepeatOff, "prev", "", -1},
+		{"out of range", 1, []int{4, -1}, RepeatOff, "next", "c", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := newlist("a", "b", "c", "d")
+			pl.SetRepeat(tt.repeat)
+			pl.Jump(tt.current)
+
+			for _, i := range tt.removes {
+				pl.Remove(i)
+			}
+			if cur := pl.Current(); cur != tt.wantcur {
+				t.Fatalf("current %d, want %d", cur, tt.wantcur)
+			}
+			if got := run(t, pl, tt.op); got != tt.want {
+				t.Fatalf("%s got %q, want %q", tt.op, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaylistMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  int
+		remove   int
+		from, to int
+		want     []string
+		next     string
+	}{
+		{"down", 0, -1, 0, 2, []string{"b", "c", "a", "d"}, "d"},
+		{"up", 0, -1, 3, 0, []string{"d", "a", "b", "c"}, "b"},
+		{"out of range", 0, -1, 0, 4, []string{"a", "b", "c", "d"}, "b"},
+		// b was removed while playing, c took its place and stays next
+		{"the entry after a gap", 1, 1, 1, 2, []string{"a", "d", "c"}, "c"},
+		{"over a gap", 1, 1, 0, 2, []string{"c", "d", "a"}, "c"},
+		{"behind a gap", 1, 1, 2, 0, []string{"d", "a", "c"}, "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := newlist("a", "b", "c", "d")
+			pl.Jump(tt.current)
+			if tt.remove >= 0 {
+				pl.Remove(tt.remove)
+			}
+
+			pl.Move(tt.from, tt.to)
+			if got := paths(pl.Entries()); !slices.Equal(got, tt.want) {
+				t.Fatalf("entries %v, want %v", got, tt.want)
+			}
+			if got := run(t, pl, "next"); got != tt.next {
+				t.Fatalf("next got %q, want %q", got, tt.next)
+			}
+		})
+	}
+}
+
+// round plays the shuffled list to its end from nothing played
+func round(t *testing.T, pl *Playlist, n int) []string {
+	t.Helper()
+
+	var played []string
+	for range n {
+		got := run(t, pl, "next")
+		if got == "" {
+			t.Fatalf("the round stopped after %v", played)
+		}
+		played = append(played, got)
+	}
+	return played
+}
+
+func TestPlaylistShuffle(t *testing.T) {
+	all := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	pl := newlist(all...)
+
+	// the current entry comes first, every other one follows once
+	pl.Jump(2)
+	pl.SetShuffle(true)
+	played := append([]string{"c"}, round(t, pl, len(all)-1)...)
+	if got := run(t, pl, "next"); got != "" {
+		t.Fatalf("next past the shuffled end got %q", got)
+	}
+	if slices.Sort(played); !slices.Equal(played, all) {
+		t.Fatalf("shuffled round played %v", played)
+	}
+
+	// every wrap plays all of them again, in a new order
+	pl.SetRepeat(RepeatAll)
+	orders := map[string]bool{}
+	for range 20 {
+		played := round(t, pl, len(all))
+		orders[strings.Join(played, ",")] = true
+		if slices.Sort(played); !slices.Equal(played, all) {
+			t.Fatalf("round played %v", played)
+		}
+	}
+	if len(orders) < 2 {
+		t.Fatal("the order isn't shuffled again on wrap")
+	}
+
+	// off again, the list order is back
+	pl.SetShuffle(false)
+	pl.Jump(0)
+	if got := run(t, pl, "next"); got != "b" {
+		t.Fatalf("next after unshuffling got %q", got)
+	}
+}
+
+func TestPlaylistAddShuffled(t *testing.T) {
+	// the new entries land at random, try a few times
+	for range 50 {
+		pl := newlist("a", "b", "c", "d")
+		pl.SetShuffle(true)
+
+		first := round(t, pl, 2)
+		pl.Add(NewEntry("e"), NewEntry("f"))
+		rest := round(t, pl, 4)
+		if got := run(t, pl, "next"); got != "" {
+			t.Fatalf("next past the end got %q after %v %v", got, first, rest)
+		}
+
+		played := slices.Concat(first, rest)
+		if slices.Sort(played); !slices.Equal(played, []string{"a", "b", "c", "d", "e", "f"}) {
+			t.Fatalf("played %v %v", first, rest)
+		}
+	}
+}
+
+func TestPlaylistClear(t *testing.T) {
+	pl := newlist("a", "b")
+	pl.Next()
+	pl.Clear()
+
+	if pl.Len() != 0 || pl.Current() != -1 {
+		t.Fatalf("len %d current %d after clear", pl.Len(), pl.Current())
+	}
+	pl.Add(NewEntry("c"))
+	if got := run(t, pl, "next"); got != "c" {
+		t.Fatalf("next after clear got %q", got)
+	}
+}
+
+func TestIsPlaylist(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"list.m3u", true},
+		{"LIST.M3U8", true},
+		{"dir/list.pls", true},
+		{"movie.mkv", false},
+		{"m3u", false},
+		{"https://example.com/live/index.m3u8", false},
+		{"http://example.com/radio.pls", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPlaylist(tt.path); got != tt.want {
+			t.Errorf("IsPlaylist(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	entries := []Entry{
+		{Path: filepath.Join(dir, "a.mkv"), Title: "A", Duration: 61},
+		{Path: "https://example.com/b.mp4", Duration: -1},
+	}
+
+	for _, name := range []string{"list.m3u8", "list.pls"} {
+		path := filepath.Join(dir, name)
+		if err := Save(path, entries); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+		got, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+		if !slices.Equal(got, entries) {
+			t.Fatalf("%s: loaded %v, want %v", name, got, entries)
+		}
+	}
+
+	if err := Save(filepath.Join(dir, "list.txt"), entries); !errors.Is(err, ErrFormat) {
+		t.Fatalf("save as txt: %v", err)
+	}
+
+	hls := filepath.Join(dir, "index.m3u8")
+	if err := os.WriteFile(hls, []byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXTINF:4,\nseg0.ts\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(hls); !errors.Is(err, ErrHLS) {
+		t.Fatalf("load hls: %v", err)
+	}
+}
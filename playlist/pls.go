@@ -0,0 +1,112 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadPLS reads a PLS playlist, relative paths are resolved against dir
+func ReadPLS(r io.Reader, dir string) ([]Entry, error) {
+	// keys are numbered from 1 and may come in any order
+	byindex := map[int]*Entry{}
+	get := func(n int) *Entry {
+		e, ok := byindex[n]
+		if !ok {
+			e = &Entry{Duration: -1}
+			byindex[n] = e
+		}
+		return e
+	}
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, "[") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("playlist: line %d: expected key=value", n)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var field string
+		for _, f := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, f) {
+				field = f
+				break
+			}
+		}
+		if field == "" {
+			// NumberOfEntries, Version
+			continue
+		}
+
+		idx, err := strconv.Atoi(strings.TrimPrefix(key, field))
+		if err != nil {
+			return nil, fmt.Errorf("playlist: line %d: bad key %q", n, key)
+		}
+
+		e := get(idx)
+		switch field {
+		case "file":
+			e.Path = resolve(value, dir)
+		case "title":
+			e.Title = value
+		case "length":
+			if d, err := strconv.ParseFloat(value, 64); err == nil && d >= 0 {
+				e.Duration = d
+			}
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("playlist: reading pls failed: %w", err)
+	}
+
+	indexes := make([]int, 0, len(byindex))
+	for i := range byindex {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var entries []Entry
+	for _, i := range indexes {
+		if e := byindex[i]; e.Path != "" {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+// WritePLS writes entries as a version 2 PLS, paths under dir are
+// written relative to it
+func WritePLS(w io.Writer, entries []Entry, dir string) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "[playlist]")
+	for i, e := range entries {
+		n := i + 1
+		fmt.Fprintf(bw, "File%d=%s\n", n, relative(e.Path, dir))
+		if e.Title != "" {
+			fmt.Fprintf(bw, "Title%d=%s\n", n, e.Title)
+		}
+		fmt.Fprintf(bw, "Length%d=%d\n", n, seconds(e.Duration))
+	}
+	fmt.Fprintf(bw, "NumberOfEntries=%d\n", len(entries))
+	fmt.Fprintln(bw, "Version=2")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("playlist: writing pls failed: %w", err)
+	}
+	return nil
+}
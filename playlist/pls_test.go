@@ -0,0 +1,111 @@
+package playlist
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadPLS(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []Entry
+		wanterr bool
+	}{
+		{
+			name: "version 2",
+			input: "[playlist]\nFile1=a.mp3\nTitle1=A\nLength1=120\n" +
+				"File2=http://example.com/radio\nTitle2=Radio\nLength2=-1\n" +
+				"NumberOfEntries=2\nVersion=2\n",
+			want: []Entry{
+				{Path: filepath.Join(dir, "a.mp3"), Title: "A", Duration: 120},
+				{Path: "http://example.com/radio", Title: "Radio", Duration: -1},
+			},
+		},
+		{
+			name: "keys out of order",
+			input: "[playlist]\nTitle2=B\nFile2=b.mp3\nLength1=3\nFile10=j.mp3\n" +
+				"File1=a.mp3\nNumberOfEntries=3\n",
+			want: []Entry{
+				{Path: filepath.Join(dir, "a.mp3"), Duration: 3},
+				{Path: filepath.Join(dir, "b.mp3"), Title: "B", Duration: -1},
+				NewEntry(filepath.Join(dir, "j.mp3")),
+			},
+		},
+		{
+			name:  "bom, crlf and case",
+			input: "\ufeff[playlist]\r\nfile1 = sub/a.mp3\r\nTITLE1=A\r\n",
+			want:  []Entry{{Path: filepath.Join(dir, "sub", "a.mp3"), Title: "A", Duration: -1}},
+		},
+		{
+			name:  "comments and entries without file",
+			input: "[playlist]\n; a comment\nTitle1=Orphan\nFile2=b.mp3\n",
+			want:  []Entry{NewEntry(filepath.Join(dir, "b.mp3"))},
+		},
+		{
+			name:    "not key=value",
+			input:   "[playlist]\nFile1\n",
+			wanterr: true,
+		},
+		{
+			name:    "bad index",
+			input:   "[playlist]\nFileX=a.mp3\n",
+			wanterr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPLS(strings.NewReader(tt.input), dir)
+			if (err != nil) != tt.wanterr {
+				t.Fatalf("error %v, want one: %v", err, tt.wanterr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritePLS(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(filepath.Dir(dir), "outside.mkv")
+
+	entries := []Entry{
+		{Path: filepath.Join(dir, "sub", "a.mkv"), Title: "A", Duration: 61.4},
+		{Path: outside, Duration: -1},
+		{Path: "https://example.com/b.mp4", Title: "B", Duration: 10},
+	}
+
+	want := "[playlist]\n" +
+		"File1=sub/a.mkv\nTitle1=A\nLength1=61\n" +
+		"File2=" + outside + "\nLength2=-1\n" +
+		"File3=https://example.com/b.mp4\nTitle3=B\nLength3=10\n" +
+		"NumberOfEntries=3\nVersion=2\n"
+
+	var buf bytes.Buffer
+	if err := WritePLS(&buf, entries, dir); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Fatalf("wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	got, err := ReadPLS(&buf, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := []Entry{
+		{Path: entries[0].Path, Title: "A", Duration: 61},
+		NewEntry(outside),
+		entries[2],
+	}
+	if !slices.Equal(got, read) {
+		t.Fatalf("read back %v, want %v", got, read)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"GoldenFealla/go-video-player/playlist"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+)
+
+var repeatmodes = []playlist.Repeat{playlist.RepeatOff, playlist.RepeatOne, playlist.RepeatAll}
+
+// playlistpanel lists the playlist, entries are played with a double
+// click, moved with the arrows and removed with x
+type playlistpanel struct {
+	open bool
+	// file the playlist is saved to or loaded from
+	file string
+	// why the last action failed
+	err string
+}
+
+func newplaylistpanel() *playlistpanel {
+	return &playlistpanel{file: "playlist.m3u8"}
+}
+
+func (pp *playlistpanel) toggle() {
+	pp.open = !pp.open
+}
+
+// fail shows err in the panel
+func (pp *playlistpanel) fail(err error) {
+	log.Println(err)
+	pp.err = err.Error()
+}
+
+func (pp *playlistpanel) draw(s *session, w, h float32) {
+	if !pp.open {
+		return
+	}
+
+	imgui.SetNextWindowPosV(imgui.Vec2{X: w - 16, Y: 16}, imgui.CondAppearing, imgui.Vec2{X: 1, Y: 0})
+	imgui.SetNextWindowSizeV(imgui.Vec2{X: min(w*0.4, 420), Y: min(h*0.7, 480)}, imgui.CondAppearing)
+
+	if imgui.BeginV("Playlist", &pp.open, imgui.WindowFlagsNoCollapse) {
+		pp.drawcontrols(s)
+		pp.drawfile(s)
+
+		if pp.err != "" {
+			imgui.TextColored(imgui.Vec4{X: 1, Y: 0.4, Z: 0.4, W: 1}, escape(pp.err))
+		}
+
+		imgui.Separator()
+		if imgui.BeginChildStrV("##playlist", imgui.Vec2{}, imgui.ChildFlagsBorders, 0) {
+			pp.drawentries(s)
+		}
+		imgui.EndChild()
+	}
+	imgui.End()
+}
+
+func (pp *playlistpanel) drawcontrols(s *session) {
+	if imgui.Button("Prev") {
+		if err := s.prev(); err != nil {
+			pp.fail(err)
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Next") {
+		if err := s.next(); err != nil {
+			pp.fail(err)
+		}
+	}
+	imgui.SameLine()
+
+	shuffle := s.list.Shuffle()
+	if imgui.Checkbox("Shuffle", &shuffle) {
+		s.list.SetShuffle(shuffle)
+	}
+	imgui.SameLine()
+
+	imgui.PushItemWidth(80)
+	if imgui.BeginCombo("Repeat", s.list.Repeat().String()) {
+		for _, r := range repeatmodes {
+			if imgui.SelectableBoolV(r.String(), r == s.list.Repeat(), 0, imgui.Vec2{}) {
+				s.list.SetRepeat(r)
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.PopItemWidth()
+	imgui.SameLine()
+
+	if imgui.Button("Clear") {
+		s.list.Clear()
+	}
+}
+
+// drawfile saves the playlist to a file or adds the one a file lists,
+// the format goes by the extension
+func (pp *playlistpanel) drawfile(s *session) {
+	imgui.PushItemWidth(-110)
+	imgui.InputTextWithHint("##file", "playlist.m3u8", &pp.file, 0, nil)
+	imgui.PopItemWidth()
+
+	imgui.SameLine()
+	if imgui.Button("Save") {
+		pp.err = ""
+		if err := playlist.Save(pp.file, s.list.Entries()); err != nil {
+			pp.fail(err)
+		}
+	}
+
+	imgui.SameLine()
+	if imgui.Button("Load") {
+		pp.err = ""
+		es, err := playlist.Load(pp.file)
+		if err != nil {
+			pp.fail(err)
+			return
+		}
+		s.list.Add(es...)
+	}
+}
+
+func (pp *playlistpanel) drawentries(s *session) {
+	entries := s.list.Entries()
+	current := s.list.Current()
+	shuffled := s.list.Shuffle()
+
+	// applied once the list is drawn
+	remove, jump := -1, -1
+	from, to := -1, -1
+
+	for i, e := range entries {
+		imgui.PushIDInt(int32(i))
+
+		// the shuffled order doesn't follow the list, moving would do
+		// nothing visible
+		imgui.BeginDisabledV(shuffled)
+		if imgui.ArrowButton("up", imgui.DirUp) && i > 0 {
+			from, to = i, i-1
+		}
+		imgui.SameLine()
+		if imgui.ArrowButton("down", imgui.DirDown) && i < len(entries)-1 {
+			from, to = i, i+1
+		}
+		imgui.EndDisabled()
+		imgui.SameLine()
+		if imgui.Button("x") {
+			remove = i
+		}
+		imgui.SameLine()
+
+		label := fmt.Sprintf("%d. %s", i+1, e.Name())
+		if e.Duration >= 0 {
+			label += "  " + formatDuration(float32(e.Duration))
+		}
+		if imgui.SelectableBoolV(label, i == current, imgui.SelectableFlagsAllowDoubleClick, imgui.Vec2{}) &&
+			imgui.IsMouseDoubleClicked(imgui.MouseButtonLeft) {
+			jump = i
+		}
+		if imgui.IsItemHovered() {
+			// Clean would fold the // of a URL
+			tip := e.Path
+			if !strings.Contains(tip, "://") {
+				tip = filepath.Clean(tip)
+			}
+			imgui.SetTooltip(escape(tip))
+		}
+
+		imgui.PopID()
+	}
+
+	switch {
+	case jump >= 0:
+		pp.err = ""
+		if err := s.jump(jump); err != nil {
+			pp.fail(err)
+		}
+	case remove >= 0:
+		s.list.Remove(remove)
+	case from >= 0:
+		s.list.Move(from, to)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxrecent is how many recent files are remembered
+const maxrecent = 10
+
+// recentfiles is the list of the last media opened, most recent first,
+// kept in the user config directory between runs
+type recentfiles struct {
+	path  string
+	files []string
+}
+
+func recentpath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-video-player", "recent")
+}
+
+// loadrecent reads the recent files, a missing list is an empty one
+func loadrecent() *recentfiles {
+	r := &recentfiles{path: recentpath()}
+	if r.path == "" {
+		return r
+	}
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return r
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() && len(r.files) < maxrecent {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			r.files = append(r.files, line)
+		}
+	}
+	return r
+}
+
+// add puts path at the top of the list and saves it
+func (r *recentfiles) add(path string) error {
+	// URLs are kept as they are
+	if !strings.Contains(path, "://") {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	r.files = slices.DeleteFunc(r.files, func(f string) bool { return f == path })
+	r.files = slices.Insert(r.files, 0, path)
+	r.files = r.files[:min(len(r.files), maxrecent)]
+
+	return r.save()
+}
+
+// clear forgets every recent file
+func (r *recentfiles) clear() error {
+	r.files = nil
+	return r.save()
+}
+
+func (r *recentfiles) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("recent: saving failed: %w", err)
+	}
+
+	data := strings.Join(r.files, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	if err := os.WriteFile(r.path, []byte(data), 0o644); err != nil {
+		return fmt.Errorf("recent: saving failed: %w", err)
+	}
+	return nil
+}
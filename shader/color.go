@@ -0,0 +1,156 @@
+package shader
+
+import (
+	"GoldenFealla/go-video-player/codec"
+
+	"github.com/asticode/go-astiav"
+	"github.com/go-gl/gl/v4.6-compatibility/gl"
+)
+
+type Matrix int
+
+const (
+	MatrixAuto Matrix = iota
+	MatrixBT601
+	MatrixBT709
+	MatrixBT2020
+)
+
+func (m Matrix) String() string {
+	switch m {
+	case MatrixAuto:
+		return "auto"
+	case MatrixBT601:
+		return "bt601"
+	case MatrixBT709:
+		return "bt709"
+	case MatrixBT2020:
+		return "bt2020"
+	}
+	return "unknown"
+}
+
+type Range int
+
+const (
+	RangeAuto Range = iota
+	RangeLimited
+	RangeFull
+)
+
+func (r Range) String() string {
+	switch r {
+	case RangeAuto:
+		return "auto"
+	case RangeLimited:
+		return "limited"
+	case RangeFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+var overrideMatrix = MatrixAuto
+var overrideRange = RangeAuto
+
+// SetColorOverride forces the YUV to RGB matrix and range instead of the
+// ones tagged in the stream, MatrixAuto and RangeAuto go back to the tags
+func SetColorOverride(m Matrix, r Range) {
+	overrideMatrix = m
+	overrideRange = r
+}
+
+func ColorOverride() (Matrix, Range) {
+	return overrideMatrix, overrideRange
+}
+
+// matrixof picks the matrix tagged on frame, untagged content is guessed
+// from its size the same way ffmpeg does
+func matrixof(frame *codec.VideoData) Matrix {
+	if overrideMatrix != MatrixAuto {
+		return overrideMatrix
+	}
+
+	switch frame.ColorSpace {
+	case astiav.ColorSpaceBt709:
+		return MatrixBT709
+	case astiav.ColorSpaceBt2020Ncl, astiav.ColorSpaceBt2020Cl:
+		return MatrixBT2020
+	case astiav.ColorSpaceBt470Bg, astiav.ColorSpaceSmpte170M, astiav.ColorSpaceFcc:
+		return MatrixBT601
+	}
+
+	if frame.W >= 1280 || frame.H > 576 {
+		return MatrixBT709
+	}
+	return MatrixBT601
+}
+
+func rangeof(frame *codec.VideoData) Range {
+	if overrideRange != RangeAuto {
+		return overrideRange
+	}
+
+	switch frame.ColorRange {
+	case astiav.ColorRangeJpeg:
+		return RangeFull
+	case astiav.ColorRangeMpeg:
+		return RangeLimited
+	}
+
+	switch frame.Format {
+	case astiav.PixelFormatYuvj420P, astiav.PixelFormatYuvj422P, astiav.PixelFormatYuvj444P:
+		return RangeFull
+	}
+	return RangeLimited
+}
+
+// yuvmatrix returns the column major YUV to RGB matrix for m
+func yuvmatrix(m Matrix) [9]float32 {
+	var kr, kb float32
+	switch m {
+	case MatrixBT709:
+		kr, kb = 0.2126, 0.0722
+	case MatrixBT2020:
+		kr, kb = 0.2627, 0.0593
+	default:
+		kr, kb = 0.299, 0.114
+	}
+	kg := 1 - kr - kb
+
+	return [9]float32{
+		// Y
+		1, 1, 1,
+		// U
+		0, -2 * kb * (1 - kb) / kg, 2 * (1 - kb),
+		// V
+		2 * (1 - kr), -2 * kr * (1 - kr) / kg, 0,
+	}
+}
+
+// yuvrange returns the offset and scale bringing samples normalised to
+// the 0..1 range of a depth bit word to Y in 0..1 and U, V in -0.5..0.5
+func yuvrange(r Range, depth int) ([3]float32, [3]float32) {
+	peak := float32(int(1)<<depth - 1)
+	unit := float32(int(1) << (depth - 8))
+
+	if r == RangeFull {
+		c := 128 * unit / peak
+		return [3]float32{0, c, c}, [3]float32{1, 1, 1}
+	}
+
+	y := 16 * unit / peak
+	c := 128 * unit / peak
+	ys := peak / (219 * unit)
+	cs := peak / (224 * unit)
+	return [3]float32{y, c, c}, [3]float32{ys, cs, cs}
+}
+
+func setColorUniforms(pl *pipeline, frame *codec.VideoData) {
+	m := yuvmatrix(matrixof(frame))
+	offset, scale := yuvrange(rangeof(frame), pl.depth)
+
+	gl.UniformMatrix3fv(gl.GetUniformLocation(pl.program, gl.Str("yuvmatrix\x00")), 1, false, &m[0])
+	gl.Uniform3fv(gl.GetUniformLocation(pl.program, gl.Str("yuvoffset\x00")), 1, &offset[0])
+	gl.Uniform3fv(gl.GetUniformLocation(pl.program, gl.Str("yuvscale\x00")), 1, &scale[0])
+}
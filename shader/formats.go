@@ -0,0 +1,141 @@
+package shader
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astiav"
+	"github.com/go-gl/gl/v4.6-compatibility/gl"
+)
+
+// planetex is how one plane is stored on the GPU
+type planetex struct {
+	internal int32
+	format   uint32
+	xtype    uint32
+}
+
+var (
+	texR8   = planetex{gl.R8, gl.RED, gl.UNSIGNED_BYTE}
+	texRG8  = planetex{gl.RG8, gl.RG, gl.UNSIGNED_BYTE}
+	texR16  = planetex{gl.R16, gl.RED, gl.UNSIGNED_SHORT}
+	texRG16 = planetex{gl.RG16, gl.RG, gl.UNSIGNED_SHORT}
+)
+
+// pipeline is the program and texture layout used to draw one pixel format
+type pipeline struct {
+	fragment string
+	planes   []planetex
+	samplers []string
+	// bits per sample
+	depth int
+
+	program uint32
+}
+
+var pipelines map[astiav.PixelFormat]*pipeline
+
+// yuv2rgbSource takes samples normalised to 0..1 and applies the range
+// expansion and matrix set by setColorUniforms
+const yuv2rgbSource = `
+	uniform mat3 yuvmatrix;
+	uniform vec3 yuvoffset;
+	uniform vec3 yuvscale;
+
+	vec3 yuv2rgb(vec3 yuv) {
+		return yuvmatrix * ((yuv - yuvoffset) * yuvscale);
+	}
+`
+
+// planarFragment samples Y, U and V from three single channel textures,
+// depthscale brings values stored in 16 bits back to the 0..1 range
+func planarFragment(depthscale float64) string {
+	return fmt.Sprintf(`
+		#version 130
+		varying vec2 vTexCoord;
+
+		uniform sampler2D texY;
+		uniform sampler2D texU;
+		uniform sampler2D texV;
+
+		const float depthscale = %f;
+		%s
+		%s
+		void main() {
+			float y = texture2D(texY, vTexCoord).r;
+			float u = texture2D(texU, vTexCoord).r;
+			float v = texture2D(texV, vTexCoord).r;
+
+			gl_FragColor = vec4(tonemap(yuv2rgb(vec3(y, u, v) * depthscale)), 1.0);
+		}
+	`, depthscale, yuv2rgbSource, tonemapSource)
+}
+
+// semiPlanarFragment samples Y from a single channel texture and U, V
+// interleaved from a two channel one
+func semiPlanarFragment(depthscale float64) string {
+	return fmt.Sprintf(`
+		#version 130
+		varying vec2 vTexCoord;
+
+		uniform sampler2D texY;
+		uniform sampler2D texUV;
+
+		const float depthscale = %f;
+		%s
+		%s
+		void main() {
+			float y = texture2D(texY, vTexCoord).r;
+			vec2 uv = texture2D(texUV, vTexCoord).rg;
+
+			gl_FragColor = vec4(tonemap(yuv2rgb(vec3(y, uv) * depthscale)), 1.0);
+		}
+	`, depthscale, yuv2rgbSource, tonemapSource)
+}
+
+// 10 bit samples stored in the low bits of 16 bit words
+const depth10lsb = 65535.0 / 1023.0
+
+// 10 bit samples stored in the high bits of 16 bit words (P010)
+const depth10msb = 65535.0 / (1023.0 * 64.0)
+
+func initPipelines() {
+	planar8 := func() *pipeline {
+		return &pipeline{
+			fragment: planarFragment(1.0),
+			planes:   []planetex{texR8, texR8, texR8},
+			samplers: []string{"texY", "texU", "texV"},
+			depth:    8,
+		}
+	}
+
+	pipelines = map[astiav.PixelFormat]*pipeline{
+		astiav.PixelFormatYuv420P:  planar8(),
+		astiav.PixelFormatYuvj420P: planar8(),
+		astiav.PixelFormatYuv422P:  planar8(),
+		astiav.PixelFormatYuvj422P: planar8(),
+		astiav.PixelFormatYuv444P:  planar8(),
+		astiav.PixelFormatYuvj444P: planar8(),
+		astiav.PixelFormatYuv420P10Le: {
+			fragment: planarFragment(depth10lsb),
+			planes:   []planetex{texR16, texR16, texR16},
+			samplers: []string{"texY", "texU", "texV"},
+			depth:    10,
+		},
+		astiav.PixelFormatNv12: {
+			fragment: semiPlanarFragment(1.0),
+			planes:   []planetex{texR8, texRG8},
+			samplers: []string{"texY", "texUV"},
+			depth:    8,
+		},
+		astiav.PixelFormatP010Le: {
+			fragment: semiPlanarFragment(depth10msb),
+			planes:   []planetex{texR16, texRG16},
+			samplers: []string{"texY", "texUV"},
+			depth:    10,
+		},
+	}
+
+	for _, pl := range pipelines {
+		pl.program = createProgram(pl.fragment)
+	}
+}
@@ -0,0 +1,223 @@
+package shader
+
+import (
+	"log"
+	"sync"
+	"unsafe"
+
+	"GoldenFealla/go-video-player/codec"
+
+	"github.com/go-gl/gl/v4.6-compatibility/gl"
+)
+
+// slots in the ring: the one being decoded into, the frames queued in
+// the video buffer, the one on screen and the ones the GPU still reads
+const pboslots = 6
+
+// pboslot is one persistently mapped pixel unpack buffer
+type pboslot struct {
+	buf uint32
+	mem []byte
+
+	// handed out to the frame pool and not given back yet
+	used bool
+	// set once textures were uploaded from the slot, it is free again
+	// when the GPU is past it
+	fence uintptr
+}
+
+// pboring hands pixel unpack buffers mapped once for their whole life to
+// the frame pool. The decoder copies pictures straight into them and the
+// textures are uploaded from there asynchronously, there is no copy out
+// of Go memory left.
+//
+// Only the GL thread makes GL calls, it polls the fences and grows the
+// slots to the size the decoder asked for. Alloc and Free, called from
+// any goroutine, only flip flags.
+type pboring struct {
+	mu    sync.Mutex
+	slots [pboslots]pboslot
+
+	// the largest size Alloc was asked for, and one mapping failed for
+	want   int
+	failed int
+}
+
+// ring is nil when persistent mapping isn't available, textures are then
+// uploaded straight from the frame planes
+var ring *pboring
+
+// buffer storage is core in 4.4, and often exposed as an extension below
+func supportspersistent() bool {
+	var major, minor int32
+	gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+	gl.GetIntegerv(gl.MINOR_VERSION, &minor)
+	if major > 4 || major == 4 && minor >= 4 {
+		return true
+	}
+
+	var n int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &n)
+	for i := range uint32(n) {
+		if gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i)) == "GL_ARB_buffer_storage" {
+			return true
+		}
+	}
+	return false
+}
+
+func initPBORing() {
+	if !supportspersistent() {
+		log.Println("shader: persistent buffers unsupported, uploading from client memory")
+		return
+	}
+	ring = &pboring{}
+}
+
+// FrameAllocator returns the allocator decoded frames should come from so
+// that they are uploaded without a copy, nil when persistent mapping
+// isn't available. Init must have run.
+func FrameAllocator() codec.Allocator {
+	if ring == nil {
+		return nil
+	}
+	return ring
+}
+
+// Alloc hands out a free slot of at least size bytes. Until the GL thread
+// grew the slots to size the frame pool falls back to Go memory.
+func (r *pboring) Alloc(size int) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.want = max(r.want, size)
+	for i := range r.slots {
+		s := &r.slots[i]
+		if !s.used && s.fence == 0 && len(s.mem) >= size {
+			s.used = true
+			return s.mem[:size:size], true
+		}
+	}
+	return nil, false
+}
+
+// Free takes back a slot Alloc handed out, it can be handed out again
+// once the GPU is done with it
+func (r *pboring) Free(buf []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.slotof(unsafe.SliceData(buf))
+	if s == nil {
+		return false
+	}
+	s.used = false
+	return true
+}
+
+// slotof returns the slot p points into, nil for other memory. r.mu must
+// be held.
+func (r *pboring) slotof(p *byte) *pboslot {
+	if p == nil {
+		return nil
+	}
+
+	addr := uintptr(unsafe.Pointer(p))
+	for i := range r.slots {
+		s := &r.slots[i]
+		if len(s.mem) == 0 {
+			continue
+		}
+		base := uintptr(unsafe.Pointer(&s.mem[0]))
+		if addr >= base && addr < base+uintptr(len(s.mem)) {
+			return s
+		}
+	}
+	return nil
+}
+
+// reclaim frees the slots the GPU is done reading and grows the idle
+// ones to the size last asked for, it runs on the GL thread
+func (r *pboring) reclaim() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.slots {
+		s := &r.slots[i]
+		if s.fence != 0 {
+			if gl.ClientWaitSync(s.fence, gl.SYNC_FLUSH_COMMANDS_BIT, 0) == gl.TIMEOUT_EXPIRED {
+				continue
+			}
+			gl.DeleteSync(s.fence)
+			s.fence = 0
+		}
+
+		if !s.used && len(s.mem) < r.want && r.want != r.failed {
+			s.free()
+			if !s.alloc(r.want) {
+				log.Printf("shader: mapping a %d bytes upload buffer failed, uploading from client memory\n", r.want)
+				r.failed = r.want
+			}
+		}
+	}
+}
+
+// uploading returns the slot the planes were decoded into and the address
+// it starts at, nil when they are in Go memory
+func (r *pboring) uploading(planes [][]byte) (*pboslot, uintptr) {
+	if len(planes) == 0 {
+		return nil, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.slotof(unsafe.SliceData(planes[0]))
+	if s == nil {
+		return nil, 0
+	}
+	return s, uintptr(unsafe.Pointer(&s.mem[0]))
+}
+
+// uploaded fences the slot after the texture uploads reading it
+func (r *pboring) uploaded(s *pboslot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s.fence != 0 {
+		gl.DeleteSync(s.fence)
+	}
+	s.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+}
+
+// alloc creates and maps the buffer, storage made by BufferStorage can't
+// be resized
+func (s *pboslot) alloc(size int) bool {
+	flags := uint32(gl.MAP_WRITE_BIT | gl.MAP_PERSISTENT_BIT | gl.MAP_COHERENT_BIT)
+
+	gl.GenBuffers(1, &s.buf)
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, s.buf)
+	gl.BufferStorage(gl.PIXEL_UNPACK_BUFFER, size, nil, flags)
+	ptr := gl.MapBufferRange(gl.PIXEL_UNPACK_BUFFER, 0, size, flags)
+	gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+
+	if ptr == nil {
+		s.free()
+		return false
+	}
+	s.mem = unsafe.Slice((*byte)(ptr), size)
+	return true
+}
+
+// free unmaps and deletes the buffer, the slot must be idle
+func (s *pboslot) free() {
+	if s.buf != 0 {
+		if s.mem != nil {
+			gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, s.buf)
+			gl.UnmapBuffer(gl.PIXEL_UNPACK_BUFFER)
+			gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+		}
+		gl.DeleteBuffers(1, &s.buf)
+	}
+	*s = pboslot{}
+}
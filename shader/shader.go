@@ -1,19 +1,26 @@
 package shader
 
 import (
+	"unsafe"
+
 	"GoldenFealla/go-video-player/codec"
 
 	"github.com/go-gl/gl/v4.6-compatibility/gl"
 )
 
-var programyuv uint32
-
 func Init() {
 	initYUVTextures()
-	programyuv = createYUVShader()
+	initPipelines()
 	initQuad()
+	initPBORing()
 }
 
+// fixed attribute locations shared by every program
+const (
+	positionLoc = 0
+	texCoordLoc = 1
+)
+
 // Vertex Array Object
 var vao uint32
 
@@ -35,14 +42,11 @@ func initQuad() {
 	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
 	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STATIC_DRAW)
 
-	posLoc := uint32(gl.GetAttribLocation(programyuv, gl.Str("position\x00")))
-	uvLoc := uint32(gl.GetAttribLocation(programyuv, gl.Str("texCoord\x00")))
+	gl.EnableVertexAttribArray(positionLoc)
+	gl.VertexAttribPointerWithOffset(positionLoc, 2, gl.FLOAT, false, 4*4, 0)
 
-	gl.EnableVertexAttribArray(posLoc)
-	gl.VertexAttribPointerWithOffset(posLoc, 2, gl.FLOAT, false, 4*4, 0)
-
-	gl.EnableVertexAttribArray(uvLoc)
-	gl.VertexAttribPointerWithOffset(uvLoc, 2, gl.FLOAT, false, 4*4, 2*4)
+	gl.EnableVertexAttribArray(texCoordLoc)
+	gl.VertexAttribPointerWithOffset(texCoordLoc, 2, gl.FLOAT, false, 4*4, 2*4)
 
 	gl.BindVertexArray(0)
 }
@@ -67,49 +71,30 @@ func initYUVTextures() {
 	setup(texV)
 }
 
-func createYUVShader() uint32 {
-	vertexShaderSource := `
-		#version 130
-		attribute vec2 position;
-		attribute vec2 texCoord;
-
-		varying vec2 vTexCoord;
-		uniform vec2 scale;
-
-		void main() {
-			vTexCoord = texCoord;
-			vec2 pos = position * scale;
-			gl_Position = vec4(pos, 0.0, 1.0);
-		}
-	`
-
-	fragmentShaderSource := `
-		#version 130
-		varying vec2 vTexCoord;
-
-		uniform sampler2D texY;
-		uniform sampler2D texU;
-		uniform sampler2D texV;
-
-		void main() {
-			float y = texture2D(texY, vTexCoord).r;
-			float u = texture2D(texU, vTexCoord).r - 0.5;
-			float v = texture2D(texV, vTexCoord).r - 0.5;
+const vertexShaderSource = `
+	#version 130
+	attribute vec2 position;
+	attribute vec2 texCoord;
 
-			float r = y + 1.402 * v;
-			float g = y - 0.344 * u - 0.714 * v;
-			float b = y + 1.772 * u;
+	varying vec2 vTexCoord;
+	uniform vec2 scale;
 
-			gl_FragColor = vec4(r, g, b, 1.0);
-		}
-	`
+	void main() {
+		vTexCoord = texCoord;
+		vec2 pos = position * scale;
+		gl_Position = vec4(pos, 0.0, 1.0);
+	}
+`
 
+func createProgram(fragmentShaderSource string) uint32 {
 	vs := compile(vertexShaderSource, gl.VERTEX_SHADER)
 	fs := compile(fragmentShaderSource, gl.FRAGMENT_SHADER)
 
 	program := gl.CreateProgram()
 	gl.AttachShader(program, vs)
 	gl.AttachShader(program, fs)
+	gl.BindAttribLocation(program, positionLoc, gl.Str("position\x00"))
+	gl.BindAttribLocation(program, texCoordLoc, gl.Str("texCoord\x00"))
 	gl.LinkProgram(program)
 
 	var status int32
@@ -125,6 +110,19 @@ func createYUVShader() uint32 {
 }
 
 var lastW, lastH int
+var lastPipeline *pipeline
+
+// the frame in the textures, its planes are already released
+var current codec.VideoData
+var uploaded bool
+
+// Invalidate forces the textures to be reallocated on the next render,
+// call it when switching to another media
+func Invalidate() {
+	lastW, lastH = 0, 0
+	lastPipeline = nil
+	uploaded = false
+}
 
 const (
 	FullSize int32 = 0
@@ -136,26 +134,62 @@ const (
 	ZeroBorder  = 0
 )
 
-func RenderYUV(frame codec.VideoData, winW, winH int) {
-	ySize := frame.W * frame.H
-	uvSize := ySize / 4
-	y := frame.Data[:ySize]
-	u := frame.Data[ySize : ySize+uvSize]
-	v := frame.Data[ySize+uvSize:]
+// Render uploads frame and draws it, see Upload and Draw
+func Render(frame codec.VideoData, winW, winH int) {
+	Upload(frame)
+	Draw(winW, winH)
+}
+
+// Upload copies the planes of frame into the textures and hands them
+// back to the frame pool, Draw then shows it as often as needed
+func Upload(frame codec.VideoData) {
+	defer frame.Release()
 
-	upload := func(tex uint32, data []byte, w, h int) {
-		gl.BindTexture(gl.TEXTURE_2D, tex)
-		if frame.W != lastW || frame.H != lastH {
+	pl, ok := pipelines[frame.Format]
+	if !ok {
+		return
+	}
+
+	realloc := frame.W != lastW || frame.H != lastH || pl != lastPipeline
+	textures := [...]uint32{texY, texU, texV}
+
+	// a frame decoded into a mapped slot is uploaded from it, one that
+	// didn't get a slot from its planes in Go memory
+	var slot *pboslot
+	var base uintptr
+	if ring != nil {
+		ring.reclaim()
+		if slot, base = ring.uploading(frame.Planes); slot != nil {
+			gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, slot.buf)
+		}
+	}
+	pixels := func(i int) unsafe.Pointer {
+		if slot != nil {
+			return gl.PtrOffset(int(uintptr(unsafe.Pointer(unsafe.SliceData(frame.Planes[i]))) - base))
+		}
+		return gl.Ptr(frame.Planes[i])
+	}
+
+	upload := func(i int) {
+		w, h := frame.PlaneSize(i)
+		pt := pl.planes[i]
+
+		// rows may be padded past the visible width
+		gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(frame.Strides[i]/frame.PlaneBPP(i)))
+		defer gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+
+		gl.BindTexture(gl.TEXTURE_2D, textures[i])
+		if realloc {
 			gl.TexImage2D(
 				gl.TEXTURE_2D,
 				FullSize,
-				gl.R8,
+				pt.internal,
 				int32(w),
 				int32(h),
 				ZeroBorder,
-				gl.RED,
-				gl.UNSIGNED_BYTE,
-				gl.Ptr(data),
+				pt.format,
+				pt.xtype,
+				pixels(i),
 			)
 		} else {
 			gl.TexSubImage2D(
@@ -165,30 +199,53 @@ func RenderYUV(frame codec.VideoData, winW, winH int) {
 				ZeroOffsetY,
 				int32(w),
 				int32(h),
-				gl.RED,
-				gl.UNSIGNED_BYTE,
-				gl.Ptr(data),
+				pt.format,
+				pt.xtype,
+				pixels(i),
 			)
 		}
 	}
 
-	upload(texY, y, frame.W, frame.H)
-	upload(texU, u, frame.W/2, frame.H/2)
-	upload(texV, v, frame.W/2, frame.H/2)
+	for i := range pl.planes {
+		upload(i)
+	}
+	if slot != nil {
+		gl.BindBuffer(gl.PIXEL_UNPACK_BUFFER, 0)
+		ring.uploaded(slot)
+	}
+	lastW, lastH = frame.W, frame.H
+	lastPipeline = pl
 
-	gl.UseProgram(programyuv)
+	current = frame
+	current.Planes = nil
+	uploaded = true
+}
+
+// Draw draws the last uploaded frame letterboxed into a winW x winH
+// viewport with the program matching its pixel format
+func Draw(winW, winH int) {
+	if !uploaded {
+		return
+	}
+
+	frame := current
+	pl := lastPipeline
+	textures := [...]uint32{texY, texU, texV}
+
+	gl.UseProgram(pl.program)
 	sx, sy := computeScale(frame.W, frame.H, winW, winH)
-	gl.Uniform2f(gl.GetUniformLocation(programyuv, gl.Str("scale\x00")), sx, sy)
-	gl.Uniform1i(gl.GetUniformLocation(programyuv, gl.Str("texY\x00")), 0)
-	gl.Uniform1i(gl.GetUniformLocation(programyuv, gl.Str("texU\x00")), 1)
-	gl.Uniform1i(gl.GetUniformLocation(programyuv, gl.Str("texV\x00")), 2)
+	gl.Uniform2f(gl.GetUniformLocation(pl.program, gl.Str("scale\x00")), sx, sy)
+	for i, name := range pl.samplers {
+		gl.Uniform1i(gl.GetUniformLocation(pl.program, gl.Str(name+"\x00")), int32(i))
+	}
+	setColorUniforms(pl, &frame)
+	setToneMapUniforms(pl, &frame)
 
+	for i := range pl.planes {
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+		gl.BindTexture(gl.TEXTURE_2D, textures[i])
+	}
 	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, texY)
-	gl.ActiveTexture(gl.TEXTURE1)
-	gl.BindTexture(gl.TEXTURE_2D, texU)
-	gl.ActiveTexture(gl.TEXTURE2)
-	gl.BindTexture(gl.TEXTURE_2D, texV)
 
 	gl.BindVertexArray(vao)
 	gl.DrawArrays(gl.TRIANGLE_FAN, 0, 4)
@@ -0,0 +1,217 @@
+package shader
+
+import (
+	"GoldenFealla/go-video-player/codec"
+
+	"github.com/asticode/go-astiav"
+	"github.com/go-gl/gl/v4.6-compatibility/gl"
+)
+
+type ToneMap int
+
+const (
+	ToneMapClip ToneMap = iota
+	ToneMapReinhard
+	ToneMapHable
+	ToneMapBT2390
+)
+
+func (t ToneMap) String() string {
+	switch t {
+	case ToneMapClip:
+		return "clip"
+	case ToneMapReinhard:
+		return "reinhard"
+	case ToneMapHable:
+		return "hable"
+	case ToneMapBT2390:
+		return "bt2390"
+	}
+	return "unknown"
+}
+
+var toneMap = ToneMapBT2390
+
+// SetToneMap selects the operator used to bring HDR content to SDR
+func SetToneMap(t ToneMap) {
+	toneMap = t
+}
+
+func CurrentToneMap() ToneMap {
+	return toneMap
+}
+
+// luminance of SDR reference white, in cd/m² (ITU-R BT.2408)
+const sdrWhite = 203.0
+
+// peak assumed when the stream has no static metadata, in cd/m²
+const defaultPeak = 1000.0
+
+// values of the transfer uniform
+const (
+	transferSDR = 0
+	transferPQ  = 1
+	transferHLG = 2
+)
+
+// tonemapSource linearises PQ and HLG, converts BT.2020 to BT.709 and
+// maps the luminance down to SDR, SDR content is passed through
+const tonemapSource = `
+	uniform int transfer;
+	uniform int tonemapop;
+	uniform bool bt2020;
+	// content peak relative to SDR white
+	uniform float peak;
+
+	const float sdrwhite = 203.0;
+
+	const float m1 = 0.1593017578125;
+	const float m2 = 78.84375;
+	const float c1 = 0.8359375;
+	const float c2 = 18.8515625;
+	const float c3 = 18.6875;
+
+	// PQ signal to cd/m²
+	vec3 pqeotf(vec3 e) {
+		vec3 p = pow(max(e, 0.0), vec3(1.0 / m2));
+		return pow(max(p - c1, 0.0) / (c2 - c3 * p), vec3(1.0 / m1)) * 10000.0;
+	}
+
+	float pqeotf(float e) {
+		float p = pow(max(e, 0.0), 1.0 / m2);
+		return pow(max(p - c1, 0.0) / (c2 - c3 * p), 1.0 / m1) * 10000.0;
+	}
+
+	// cd/m² to PQ signal
+	float pqoetf(float nits) {
+		float y = pow(max(nits / 10000.0, 0.0), m1);
+		return pow((c1 + c2 * y) / (1.0 + c3 * y), m2);
+	}
+
+	// HLG signal to cd/m² on a 1000 cd/m² display
+	vec3 hlgeotf(vec3 e) {
+		const float a = 0.17883277;
+		const float b = 0.28466892;
+		const float c = 0.55991073;
+
+		e = max(e, 0.0);
+		vec3 lo = e * e / 3.0;
+		vec3 hi = (exp((e - c) / a) + b) / 12.0;
+		vec3 scene = mix(lo, hi, step(0.5, e));
+
+		float ys = dot(scene, vec3(0.2627, 0.6780, 0.0593));
+		return 1000.0 * pow(max(ys, 1e-6), 0.2) * scene;
+	}
+
+	float hable(float x) {
+		const float A = 0.15;
+		const float B = 0.50;
+		const float C = 0.10;
+		const float D = 0.20;
+		const float E = 0.02;
+		const float F = 0.30;
+		return ((x * (A * x + C * B) + D * E) / (x * (A * x + B) + D * F)) - E / F;
+	}
+
+	// BT.2390 EETF, runs in the PQ domain
+	float bt2390(float y) {
+		float srcmax = pqoetf(peak * sdrwhite);
+		float e = pqoetf(y * sdrwhite) / srcmax;
+		float maxlum = pqoetf(sdrwhite) / srcmax;
+		float ks = 1.5 * maxlum - 0.5;
+
+		if (e > ks) {
+			float t = (e - ks) / (1.0 - ks);
+			float t2 = t * t;
+			float t3 = t2 * t;
+			e = (2.0 * t3 - 3.0 * t2 + 1.0) * ks +
+				(t3 - 2.0 * t2 + t) * (1.0 - ks) +
+				(-2.0 * t3 + 3.0 * t2) * maxlum;
+		}
+
+		return pqeotf(e * srcmax) / sdrwhite;
+	}
+
+	float maplum(float y) {
+		if (tonemapop == 1) {
+			return y * (1.0 + y / (peak * peak)) / (1.0 + y);
+		}
+		if (tonemapop == 2) {
+			return hable(y) / hable(peak);
+		}
+		if (tonemapop == 3) {
+			return bt2390(y);
+		}
+		return min(y, 1.0);
+	}
+
+	vec3 tonemap(vec3 rgb) {
+		if (transfer == 0) {
+			return rgb;
+		}
+
+		// linear, relative to SDR white
+		if (transfer == 1) {
+			rgb = pqeotf(rgb) / sdrwhite;
+		} else {
+			rgb = hlgeotf(rgb) / sdrwhite;
+		}
+
+		if (bt2020) {
+			rgb = vec3(
+				dot(vec3( 1.6605, -0.5876, -0.0728), rgb),
+				dot(vec3(-0.1246,  1.1329, -0.0083), rgb),
+				dot(vec3(-0.0182, -0.1006,  1.1187), rgb)
+			);
+			rgb = max(rgb, 0.0);
+		}
+
+		float y = dot(rgb, vec3(0.2126, 0.7152, 0.0722));
+		if (y > 0.0) {
+			rgb *= maplum(y) / y;
+		}
+
+		return pow(clamp(rgb, 0.0, 1.0), vec3(1.0 / 2.2));
+	}
+`
+
+func transferof(frame *codec.VideoData) int32 {
+	switch frame.ColorTransfer {
+	case astiav.ColorTransferCharacteristicSmpte2084:
+		return transferPQ
+	case astiav.ColorTransferCharacteristicAribStdB67:
+		return transferHLG
+	}
+	return transferSDR
+}
+
+// peakof returns the content peak in cd/m² from the static metadata,
+// MaxCLL is preferred over the mastering display as it describes the
+// content itself
+func peakof(frame *codec.VideoData, transfer int32) float64 {
+	if transfer == transferHLG {
+		return defaultPeak
+	}
+	if cl := frame.ContentLight; cl != nil && cl.MaxCLL > 0 {
+		return float64(cl.MaxCLL)
+	}
+	if md := frame.Mastering; md != nil && md.MaxLuminance > 0 {
+		return md.MaxLuminance
+	}
+	return defaultPeak
+}
+
+func setToneMapUniforms(pl *pipeline, frame *codec.VideoData) {
+	transfer := transferof(frame)
+	peak := max(peakof(frame, transfer)/sdrWhite, 1.0)
+
+	var bt2020 int32
+	if frame.ColorPrimaries == astiav.ColorPrimariesBt2020 {
+		bt2020 = 1
+	}
+
+	gl.Uniform1i(gl.GetUniformLocation(pl.program, gl.Str("transfer\x00")), transfer)
+	gl.Uniform1i(gl.GetUniformLocation(pl.program, gl.Str("tonemapop\x00")), int32(toneMap))
+	gl.Uniform1i(gl.GetUniformLocation(pl.program, gl.Str("bt2020\x00")), bt2020)
+	gl.Uniform1f(gl.GetUniformLocation(pl.program, gl.Str("peak\x00")), float32(peak))
+}
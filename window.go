@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// fullscreenmode is how the window covers the display
+type fullscreenmode int
+
+const (
+	windowed fullscreenmode = iota
+	// a borderless window the size of the desktop, quick to switch
+	fullscreenDesktop
+	// the display is given to the window at its desktop resolution
+	fullscreenExclusive
+)
+
+// screen is the player window and its modes
+type screen struct {
+	win *sdl.Window
+
+	// the window is sized to the video times scale on load, unless its
+	// size was given
+	scale float64
+	fixed bool
+	// size of the video last fitted, 0 without video
+	videow, videoh int
+
+	// where the window was last time it was windowed
+	x, y int32
+}
+
+// newscreen creates the window, sized to the video w x h when it has
+// one and the size wasn't given, at the position it had last time
+func newscreen(o options, videow, videoh int) (*screen, error) {
+	s := &screen{
+		scale:  o.scale,
+		fixed:  o.sized,
+		videow: videow,
+		videoh: videoh,
+	}
+
+	var flags uint32 = sdl.WINDOW_OPENGL | sdl.WINDOW_RESIZABLE
+	if o.borderless {
+		flags |= sdl.WINDOW_BORDERLESS
+	}
+	if o.ontop {
+		flags |= sdl.WINDOW_ALWAYS_ON_TOP
+	}
+
+	var x, y int32 = sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED
+	display := 0
+	if px, py, d, ok := loadposition(); ok {
+		x, y, display = px, py, d
+	}
+
+	w, h := int32(o.width), int32(o.height)
+	if !o.sized && videow > 0 && videoh > 0 {
+		w, h = s.fitsize(display, videow, videoh)
+	}
+
+	win, err := sdl.CreateWindow("Player", x, y, w, h, flags)
+	if err != nil {
+		return nil, err
+	}
+	s.win = win
+	s.x, s.y = win.GetPosition()
+
+	if o.fullscreen {
+		if err := s.setfullscreen(fullscreenDesktop); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return s, nil
+}
+
+// fitsize returns the video size times the scale, shrunk to the usable
+// area of the display when it doesn't fit
+func (s *screen) fitsize(display int, videow, videoh int) (int32, int32) {
+	w := float64(videow) * s.scale
+	h := float64(videoh) * s.scale
+
+	if bounds, err := sdl.GetDisplayUsableBounds(display); err == nil && bounds.W > 0 && bounds.H > 0 {
+		if f := math.Min(float64(bounds.W)/w, float64(bounds.H)/h); f < 1 {
+			w *= f
+			h *= f
+		}
+	}
+
+	return int32(max(math.Round(w), 1)), int32(max(math.Round(h), 1))
+}
+
+func (s *screen) display() int {
+	idx, err := s.win.GetDisplayIndex()
+	if err != nil {
+		return 0
+	}
+	return idx
+}
+
+// fit resizes the window to the video w x h times the scale keeping its
+// center, a fullscreen or maximized window is left alone
+func (s *screen) fit(videow, videoh int) {
+	s.videow, s.videoh = videow, videoh
+	if s.fixed || videow <= 0 || videoh <= 0 {
+		return
+	}
+	if s.fullscreen() != windowed || s.win.GetFlags()&sdl.WINDOW_MAXIMIZED != 0 {
+		return
+	}
+
+	w, h := s.fitsize(s.display(), videow, videoh)
+	ow, oh := s.win.GetSize()
+	x, y := s.win.GetPosition()
+
+	s.win.SetSize(w, h)
+	s.win.SetPosition(x+(ow-w)/2, y+(oh-h)/2)
+	s.x, s.y = s.win.GetPosition()
+}
+
+// setscale sets the scale the video is shown at and resizes the window
+// to it right away
+func (s *screen) setscale(scale float64) {
+	s.scale = scale
+	s.fixed = false
+	s.fit(s.videow, s.videoh)
+}
+
+func (s *screen) fullscreen() fullscreenmode {
+	flags := s.win.GetFlags()
+	switch {
+	case flags&sdl.WINDOW_FULLSCREEN_DESKTOP == sdl.WINDOW_FULLSCREEN_DESKTOP:
+		return fullscreenDesktop
+	case flags&sdl.WINDOW_FULLSCREEN != 0:
+		return fullscreenExclusive
+	}
+	return windowed
+}
+
+func (s *screen) setfullscreen(m fullscreenmode) error {
+	if m == s.fullscreen() {
+		return nil
+	}
+	if s.fullscreen() == windowed {
+		s.x, s.y = s.win.GetPosition()
+	}
+
+	var flags uint32
+	switch m {
+	case fullscreenDesktop:
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	case fullscreenExclusive:
+		// keep the desktop resolution instead of the closest one to the
+		// window size
+		mode, err := sdl.GetDesktopDisplayMode(s.display())
+		if err != nil {
+			return fmt.Errorf("window: getting the display mode failed: %w", err)
+		}
+		if err := s.win.SetDisplayMode(&mode); err != nil {
+			return fmt.Errorf("window: setting the display mode failed: %w", err)
+		}
+		flags = sdl.WINDOW_FULLSCREEN
+	}
+
+	// switching between the two fullscreens goes through windowed
+	if m != windowed && s.fullscreen() != windowed {
+		if err := s.win.SetFullscreen(0); err != nil {
+			return fmt.Errorf("window: leaving fullscreen failed: %w", err)
+		}
+	}
+
+	if err := s.win.SetFullscreen(flags); err != nil {
+		return fmt.Errorf("window: switching fullscreen failed: %w", err)
+	}
+
+	if m == windowed {
+		s.win.SetPosition(s.x, s.y)
+	}
+	return nil
+}
+
+func (s *screen) borderless() bool {
+	return s.win.GetFlags()&sdl.WINDOW_BORDERLESS != 0
+}
+
+func (s *screen) setborderless(on bool) {
+	s.win.SetBordered(!on)
+}
+
+func (s *screen) ontop() bool {
+	return s.win.GetFlags()&sdl.WINDOW_ALWAYS_ON_TOP != 0
+}
+
+func (s *screen) setontop(on bool) {
+	s.win.SetAlwaysOnTop(on)
+}
+
+// moved keeps track of where the windowed window is, call it on
+// WINDOWEVENT_MOVED
+func (s *screen) moved() {
+	if s.fullscreen() == windowed && s.win.GetFlags()&sdl.WINDOW_MAXIMIZED == 0 {
+		s.x, s.y = s.win.GetPosition()
+	}
+}
+
+// close saves the window position for the next run and destroys it
+func (s *screen) close() {
+	if err := saveposition(s.x, s.y); err != nil {
+		log.Println(err)
+	}
+	s.win.Destroy()
+}
+
+// positionpath returns the file the window position is kept in
+func positionpath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-video-player", "window")
+}
+
+// loadposition returns the position saved last time and the display it
+// is on, when it is still on one of them
+func loadposition() (int32, int32, int, bool) {
+	path := positionpath()
+	if path == "" {
+		return 0, 0, 0, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	var x, y int32
+	if _, err := fmt.Sscanf(string(data), "%d %d", &x, &y); err != nil {
+		return 0, 0, 0, false
+	}
+
+	n, err := sdl.GetNumVideoDisplays()
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	for i := range n {
+		bounds, err := sdl.GetDisplayUsableBounds(i)
+		if err != nil {
+			continue
+		}
+		if x >= bounds.X && x < bounds.X+bounds.W && y >= bounds.Y && y < bounds.Y+bounds.H {
+			return x, y, i, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+func saveposition(x, y int32) error {
+	path := positionpath()
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("window: saving position failed: %w", err)
+	}
+	if err := os.WriteFile(path, fmt.Appendf(nil, "%d %d\n", x, y), 0o644); err != nil {
+		return fmt.Errorf("window: saving position failed: %w", err)
+	}
+	return nil
+}